@@ -6,7 +6,9 @@ import (
 	"io"
 	"log"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 
 	"github.com/jimmyfrasche/etlite/internal/compile"
 	"github.com/jimmyfrasche/etlite/internal/driver"
@@ -37,18 +39,90 @@ func (a *autoClose) Read(p []byte) (int, error) {
 	return n, err
 }
 
+//cancelOnSignal calls cancel when any of sigs is received,
+//until stop is called.
+//
+//Only the first signal is handled so that another stops the process
+//if cancelling takes too long.
+func cancelOnSignal(cancel func(), sigs ...os.Signal) (stop func()) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, sigs...)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-c:
+			signal.Stop(c)
+			cancel()
+		case <-done:
+		}
+	}()
+	return func() {
+		signal.Stop(c)
+		close(done)
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	s, err := f.Stat()
+	return err == nil && s.Mode()&os.ModeCharDevice != 0
+}
+
 func main() {
 	log.SetFlags(0)
 
 	var (
 		srcFile = flag.String("f", "", "source file (defaults to stdin)")
 		expr    = flag.String("e", "", "single expression")
+		inter   = flag.Bool("i", false, "interactive mode (default if neither -f nor -e and stdin is a terminal)")
+		check   = flag.Bool("check", false, "check the script without running it")
+		explain = flag.Bool("explain", false, "print the compiled program without running it")
+		trace   = flag.String("trace", "", "log each instruction run to stderr as text or json")
+		stats   = flag.String("stats", "", "report statistics of each statement to stderr or in the sys.stats table")
+		timeout = flag.Duration("timeout", 0, "stop the script if it runs longer than this (interactively, each batch of statements)")
 	)
 	flag.Parse()
 	if *srcFile != "" && *expr != "" {
 		flag.Usage()
 		log.Fatal("-f and -e are mutually exclusive")
 	}
+	if *inter && (*srcFile != "" || *expr != "") {
+		flag.Usage()
+		log.Fatal("-i cannot be used with -f or -e")
+	}
+	if *inter && *check {
+		flag.Usage()
+		log.Fatal("-i and -check are mutually exclusive")
+	}
+	if *explain && (*inter || *check) {
+		flag.Usage()
+		log.Fatal("-explain cannot be used with -i or -check")
+	}
+	var tracer *virt.Tracer
+	switch *trace {
+	case "":
+	case "text":
+		tracer = virt.NewTracer(os.Stderr, false)
+	case "json":
+		tracer = virt.NewTracer(os.Stderr, true)
+	default:
+		flag.Usage()
+		log.Fatal("-trace must be text or json")
+	}
+	if *timeout < 0 {
+		flag.Usage()
+		log.Fatal("-timeout cannot be negative")
+	}
+	if *stats != "" && *stats != "stderr" && *stats != "table" {
+		flag.Usage()
+		log.Fatal("-stats must be stderr or table")
+	}
+	if !*check && !*explain && *srcFile == "" && *expr == "" && isTerminal(os.Stdin) {
+		*inter = true
+	}
+	if *inter && *stats != "" {
+		flag.Usage()
+		log.Fatal("-stats cannot be used interactively")
+	}
 	var (
 		src       io.Reader
 		name      string
@@ -70,30 +144,77 @@ func main() {
 		usesStdin = true
 	}
 	//XXX if above, and nothing selected, try first arg?
-	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
 	err := driver.Init()
 	if err != nil {
 		log.Fatalln(err)
 	}
 
+	if *inter {
+		//^C cancels the running statement, not the session
+		if err := interactive(ctx, flag.Args(), os.Environ(), tracer, *timeout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	tokens := lex.Stream(name, src)
 	nodes := parse.Tokens(tokens)
 	db, bc, err := compile.Nodes(nodes, usesStdin) //TODO needs defined db, too?
 	if err != nil {
 		log.Fatal(err)
 	}
+	if *explain {
+		if err := virt.Disassemble(os.Stdout, bc); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if *check {
+		vm, err := virt.NewCheck(db, flag.Args(), os.Environ())
+		if err != nil {
+			log.Fatal(err)
+		}
+		vm.SetTracer(tracer)
+		errs := vm.Check(ctx, bc)
+		for _, err := range errs {
+			log.Println(err)
+		}
+		vm.Close()
+		if len(errs) > 0 {
+			os.Exit(1)
+		}
+		return
+	}
 	vm, err := virt.New(db, flag.Args(), os.Environ())
 	if err != nil {
 		log.Fatal(err)
 	}
-	if err := vm.Run(ctx, bc); err != nil {
+	stop := cancelOnSignal(cancel, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+	vm.SetTracer(tracer)
+	if *stats != "" {
+		vm.CollectStats(*stats == "table")
+	}
+	err = vm.Run(ctx, bc)
+	if *stats == "stderr" {
+		if serr := vm.WriteStats(os.Stderr); serr != nil {
+			log.Println(serr)
+		}
+	}
+	if err != nil {
 		log.Fatal(err)
 	}
 	errs := vm.Close()
 	if len(errs) > 0 {
 		log.Println("failed to shutdown database connection:")
-		for err := range errs {
+		for _, err := range errs {
 			log.Println(err)
 		}
 		os.Exit(1)
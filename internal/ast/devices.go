@@ -16,9 +16,50 @@ type Device interface {
 	dev()
 }
 
+//Compression is one of DETECT, NONE, GZIP, BZIP2, XZ, ZSTD.
+type Compression int
+
+const (
+	//DetectCompression detects the compression, if any, of a file.
+	DetectCompression Compression = iota
+	//NoCompression is an uncompressed file.
+	NoCompression
+	//Gzip is gzip compression.
+	Gzip
+	//Bzip2 is bzip2 compression.
+	Bzip2
+	//XZ is xz compression.
+	XZ
+	//Zstd is Zstandard compression.
+	Zstd
+)
+
+func (c Compression) String() string {
+	switch c {
+	case DetectCompression:
+		return "DETECT"
+	case NoCompression:
+		return "NONE"
+	case Gzip:
+		return "GZIP"
+	case Bzip2:
+		return "BZIP2"
+	case XZ:
+		return "XZ"
+	case Zstd:
+		return "ZSTD"
+	}
+	return "<UNKNOWN COMPRESSION KIND>"
+}
+
 //DeviceFile represents a named file.
+//
+//If NameExpr is not nil, the name is computed when the device is used
+//by concatenating its strings and @ arguments, and Name is its first token.
 type DeviceFile struct {
-	Name token.Value
+	Name        token.Value
+	NameExpr    *SQL
+	Compression Compression
 }
 
 var _ Device = &DeviceFile{}
@@ -33,10 +74,75 @@ func (d *DeviceFile) Pos() token.Position {
 //Print stringifies to a writer.
 func (d *DeviceFile) Print(to io.Writer) error {
 	w := writer.New(to)
-	w.Str(d.Name.Value)
+	w.Str("FILE ")
+	if d.NameExpr != nil {
+		for i, t := range d.NameExpr.Tokens {
+			if i > 0 {
+				w.Sp()
+			}
+			if t.Kind == token.Argument {
+				w.Str("@")
+			}
+			w.Str(t.Value)
+		}
+	} else {
+		w.Str(d.Name.Value)
+	}
+	if d.Compression != DetectCompression {
+		w.Str(" COMPRESSED ").Stringer(d.Compression)
+	}
 	return w.Err()
 }
 
+//DeviceFiles represents the files matching a pattern, or in a directory,
+//read one after another.
+type DeviceFiles struct {
+	Pattern     token.Value
+	Compression Compression
+	Source      bool //add a column recording the file each row was read from
+}
+
+var _ Device = &DeviceFiles{}
+
+func (*DeviceFiles) dev() {}
+
+//Pos reports the original position in input.
+func (d *DeviceFiles) Pos() token.Position {
+	return d.Pattern.Pos()
+}
+
+//Print stringifies to a writer.
+func (d *DeviceFiles) Print(to io.Writer) error {
+	w := writer.New(to)
+	w.Str("FILES ").Str(d.Pattern.Value)
+	if d.Compression != DetectCompression {
+		w.Str(" COMPRESSED ").Stringer(d.Compression)
+	}
+	if d.Source {
+		w.Str(" SOURCE")
+	}
+	return w.Err()
+}
+
+//DeviceCommand represents a command run by the shell.
+type DeviceCommand struct {
+	Command token.Value
+}
+
+var _ Device = &DeviceCommand{}
+
+func (*DeviceCommand) dev() {}
+
+//Pos reports the original position in input.
+func (d *DeviceCommand) Pos() token.Position {
+	return d.Command.Pos()
+}
+
+//Print stringifies to a writer.
+func (d *DeviceCommand) Print(to io.Writer) error {
+	return writer.New(to).Str("COMMAND ").Str(d.Command.Value).Err()
+}
+
 //DeviceStdio represents stdin or stdout, respectively.
 type DeviceStdio struct {
 	token.Position //TO or FROM
@@ -0,0 +1,31 @@
+package ast
+
+import (
+	"io"
+
+	"github.com/jimmyfrasche/etlite/internal/ast/internal/writer"
+	"github.com/jimmyfrasche/etlite/internal/token"
+)
+
+//ForEach runs Body once for each row of Query,
+//with the columns of the row bound to script variables.
+type ForEach struct {
+	token.Position
+	Query *SQL
+	Body  []Node
+}
+
+var _ Node = (*ForEach)(nil)
+
+func (*ForEach) node() {}
+
+//Print stringifies to a writer.
+func (n *ForEach) Print(to io.Writer) error {
+	w := writer.New(to)
+	w.Str("FOR EACH ROW IN (")
+	_ = n.Query.Print(w)
+	w.Str(") DO").Nl()
+	printBlock(w, n.Body)
+	w.Str("END")
+	return w.Err()
+}
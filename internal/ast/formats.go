@@ -40,12 +40,15 @@ func (l LineEnding) String() string {
 	return "<UNKNOWN LINE ENDING KIND>"
 }
 
-//FormatCSV represents: csv [delim] [quote] [line] [null] [header]
+//FormatCSV represents: csv [strict] [delim] [quote] [escape] [comment] [trim] [line] [null] [header]
 type FormatCSV struct {
 	token.Position
 	Strict   bool
 	Delim    rune
 	Quote    rune
+	Escape   rune
+	Comment  rune
+	Trim     bool
 	Line     LineEnding
 	Null     null.Encoding
 	NoHeader bool
@@ -60,6 +63,10 @@ func (f *FormatCSV) Print(to io.Writer) error {
 	w := writer.New(to)
 	w.Str("CSV")
 
+	if f.Strict {
+		w.Str(" STRICT")
+	}
+
 	if f.Delim > 0 {
 		w.Str(" DELIMITER ").Rune(f.Delim)
 	}
@@ -68,6 +75,18 @@ func (f *FormatCSV) Print(to io.Writer) error {
 		w.Str(" QUOTE ").Rune(f.Quote)
 	}
 
+	if f.Escape > 0 {
+		w.Str(" ESCAPE ").Rune(f.Escape)
+	}
+
+	if f.Comment > 0 {
+		w.Str(" COMMENT ").Rune(f.Comment)
+	}
+
+	if f.Trim {
+		w.Str(" TRIM")
+	}
+
 	if f.Null != "" {
 		w.Str(" NULL ").Str(escape.String(string(f.Null)))
 	}
@@ -117,9 +136,11 @@ func (f *FormatRaw) Print(to io.Writer) error {
 	return w.Err()
 }
 
-//FormatJSON is a JSON format directive in a display or import statement.
+//FormatJSON represents json [strict] [arrays]
 type FormatJSON struct {
 	token.Position
+	Strict bool
+	Arrays bool
 }
 
 var _ Format = (*FormatJSON)(nil)
@@ -130,5 +151,68 @@ func (*FormatJSON) fmt() {}
 func (f *FormatJSON) Print(to io.Writer) error {
 	w := writer.New(to)
 	w.Str("JSON")
+
+	if f.Strict {
+		w.Str(" STRICT")
+	}
+
+	if f.Arrays {
+		w.Str(" ARRAYS")
+	}
+
+	return w.Err()
+}
+
+//FormatNDJSON represents ndjson [strict] [sample n]
+type FormatNDJSON struct {
+	token.Position
+	Strict bool
+	Sample int
+}
+
+var _ Format = (*FormatNDJSON)(nil)
+
+func (*FormatNDJSON) fmt() {}
+
+//Print stringifies to a writer.
+func (f *FormatNDJSON) Print(to io.Writer) error {
+	w := writer.New(to)
+	w.Str("NDJSON")
+
+	if f.Strict {
+		w.Str(" STRICT")
+	}
+
+	if f.Sample > 0 {
+		w.Str(" SAMPLE ").Int(f.Sample)
+	}
+
+	return w.Err()
+}
+
+//FormatXLSX represents xlsx [strict] [noheader]
+type FormatXLSX struct {
+	token.Position
+	Strict   bool
+	NoHeader bool
+}
+
+var _ Format = (*FormatXLSX)(nil)
+
+func (*FormatXLSX) fmt() {}
+
+//Print stringifies to a writer.
+func (f *FormatXLSX) Print(to io.Writer) error {
+	w := writer.New(to)
+	w.Str("XLSX")
+
+	if f.Strict {
+		w.Str(" STRICT")
+	}
+
+	if f.NoHeader {
+		w.Str(" NOHEADER")
+	}
+
 	return w.Err()
 }
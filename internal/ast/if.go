@@ -0,0 +1,47 @@
+package ast
+
+import (
+	"io"
+
+	"github.com/jimmyfrasche/etlite/internal/ast/internal/writer"
+	"github.com/jimmyfrasche/etlite/internal/token"
+)
+
+//If runs Then if the scalar subquery Cond returns 1
+//and Else otherwise.
+type If struct {
+	token.Position
+	Cond       *SQL
+	Then, Else []Node
+}
+
+var _ Node = (*If)(nil)
+
+func (*If) node() {}
+
+//Print stringifies to a writer.
+func (n *If) Print(to io.Writer) error {
+	w := writer.New(to)
+	w.Str("IF (")
+	_ = n.Cond.Print(w)
+	w.Str(") THEN").Nl()
+	printBlock(w, n.Then)
+	if len(n.Else) > 0 {
+		w.Str("ELSE").Nl()
+		printBlock(w, n.Else)
+	}
+	w.Str("END IF")
+	return w.Err()
+}
+
+func printBlock(w *writer.Writer, ns []Node) {
+	for _, n := range ns {
+		w.Tab()
+		_ = n.Print(w)
+		if _, ok := n.(*SQL); !ok {
+			//SQL includes its semicolon
+			w.Str(";")
+		}
+		w.Nl()
+	}
+}
@@ -4,35 +4,32 @@ import (
 	"io"
 
 	"github.com/jimmyfrasche/etlite/internal/ast/internal/writer"
+	"github.com/jimmyfrasche/etlite/internal/internal/escape"
 	"github.com/jimmyfrasche/etlite/internal/token"
 )
 
-//Import [temp] [table] [header] [device] [format] [frame] [limit] [offset]
+//Import [temp] [table] [header] [device] [format] [frame] [infer] [limit] [offset] [on conflict] [max errors] [rejects]
 type Import struct {
 	token.Position
-	Temporary bool
-	Name      Name
-	Header    []string
-	Device    Device
-	Format    Format
-	Frame     string
-	Limit     int
-	Offset    int
+	Temporary  bool
+	Name       Name
+	Header     []string
+	Types      []string //declared type of each column in Header, if any
+	Device     Device
+	Format     Format
+	Frame      string
+	InferTypes int //number of rows to sample to infer types, if positive
+	Limit      int
+	Offset     int
+	Conflict   string //IGNORE, REPLACE, or ABORT, if ON CONFLICT is specified
+	MaxErrors  int    //number of rows that may be rejected, if not negative
+	Rejects    string //file to write rejected rows to, if any
 }
 
 var _ Node = (*Import)(nil)
 
 func (*Import) node() {}
 
-func printCols(w *writer.Writer, cols []string) {
-	for i, c := range cols {
-		w.Str(c)
-		if i != len(cols)-1 {
-			w.Str(", ")
-		}
-	}
-}
-
 //Print stringifies to a writer.
 func (i *Import) Print(to io.Writer) error {
 	w := writer.New(to)
@@ -53,7 +50,15 @@ func (i *Import) print(w *writer.Writer) {
 
 	if len(i.Header) > 0 {
 		w.Str("(")
-		printCols(w, i.Header)
+		for j, c := range i.Header {
+			w.Str(c)
+			if j < len(i.Types) && i.Types[j] != "" {
+				w.Sp().Str(i.Types[j])
+			}
+			if j != len(i.Header)-1 {
+				w.Str(", ")
+			}
+		}
 		w.Str(") ")
 	}
 
@@ -73,6 +78,10 @@ func (i *Import) print(w *writer.Writer) {
 		w.Str("FRAME ").Str(i.Frame).Sp()
 	}
 
+	if i.InferTypes > 0 {
+		w.Str("INFER TYPES ").Int(i.InferTypes).Sp()
+	}
+
 	if i.Limit > 0 {
 		w.Str("LIMIT ").Int(i.Limit).Sp()
 	}
@@ -80,4 +89,16 @@ func (i *Import) print(w *writer.Writer) {
 	if i.Offset > 0 {
 		w.Str("OFFSET ").Int(i.Offset).Sp()
 	}
+
+	if i.Conflict != "" {
+		w.Str("ON CONFLICT ").Str(i.Conflict).Sp()
+	}
+
+	if i.MaxErrors >= 0 {
+		w.Str("MAX ERRORS ").Int(i.MaxErrors).Sp()
+	}
+
+	if i.Rejects != "" {
+		w.Str("REJECTS TO FILE ").Str(escape.String(i.Rejects)).Sp()
+	}
 }
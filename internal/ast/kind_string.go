@@ -4,9 +4,9 @@ package ast
 
 import "fmt"
 
-const _Kind_name = "InvalidQueryExecCreateTableFromInsertUsingSavepointReleaseBeginTransactionCommit"
+const _Kind_name = "InvalidQueryExecCreateTableFromInsertUsingSavepointReleaseBeginTransactionCommitRollback"
 
-var _Kind_index = [...]uint8{0, 7, 12, 16, 31, 42, 51, 58, 74, 80}
+var _Kind_index = [...]uint8{0, 7, 12, 16, 31, 42, 51, 58, 74, 80, 88}
 
 func (i Kind) String() string {
 	if i < 0 || i >= Kind(len(_Kind_index)-1) {
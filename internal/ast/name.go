@@ -20,9 +20,9 @@ type Name struct {
 func MakeName(tokens []token.Value) (Name, error) {
 	lt := len(tokens)
 	if lt != 1 && lt != 3 {
-		return Name{}, errint.Newf("MakeName given %d tokens")
+		return Name{}, errint.Newf("MakeName given %d tokens", lt)
 	}
-	if k := tokens[0].Kind; k != token.Literal || k != token.String {
+	if k := tokens[0].Kind; k != token.Literal && k != token.String {
 		kind := "name"
 		if lt == 1 {
 			kind = "schema"
@@ -38,7 +38,7 @@ func MakeName(tokens []token.Value) (Name, error) {
 	if !tokens[1].Literal(".") {
 		return Name{}, errint.Newf("MakeName given malformed Name: %#v", tokens)
 	}
-	if k := tokens[2].Kind; k != token.Literal || k != token.String {
+	if k := tokens[2].Kind; k != token.Literal && k != token.String {
 		return Name{}, errint.Newf("MakeName on schema %s given invalid name %#v", tokens[0].Value, tokens[2])
 	}
 	return Name{
@@ -136,13 +136,13 @@ func (n Name) DigitalObject() bool {
 	return n.hasObject && digital.String(n.Object())
 }
 
-//Reserved reports whether n is sys.args or sys.env.
+//Reserved reports whether n is sys.args, sys.env, or sys.vars.
 func (n Name) Reserved() bool {
 	if !n.hasObject || !n.OnSys() {
 		return false
 	}
 	s := strings.ToLower(n.Object())
-	return s == "args" || s == "env"
+	return s == "args" || s == "env" || s == "vars"
 }
 
 //WithoutSchema returns a new Name without a schema.
@@ -0,0 +1,59 @@
+package ast
+
+import (
+	"io"
+	"time"
+
+	"github.com/jimmyfrasche/etlite/internal/ast/internal/writer"
+	"github.com/jimmyfrasche/etlite/internal/token"
+)
+
+//SetTimeout bounds the runtime of each following statement.
+//A Timeout of 0 removes the bound.
+type SetTimeout struct {
+	token.Position
+	Timeout time.Duration
+}
+
+var _ Node = (*SetTimeout)(nil)
+
+func (*SetTimeout) node() {}
+
+//Print stringifies to a writer.
+func (s *SetTimeout) Print(to io.Writer) error {
+	w := writer.New(to)
+	w.Str("SET TIMEOUT ")
+	if s.Timeout == 0 {
+		w.Str("NONE")
+	} else {
+		w.Str("'").Str(s.Timeout.String()).Str("'")
+	}
+	return w.Err()
+}
+
+//SetVar sets the script variable Name to the result of Subquery
+//or, if Subquery is nil, to Value.
+type SetVar struct {
+	token.Position
+	Name     token.Value //the @ argument
+	Subquery *SQL
+	Value    token.Value
+}
+
+var _ Node = (*SetVar)(nil)
+
+func (*SetVar) node() {}
+
+//Print stringifies to a writer.
+func (s *SetVar) Print(to io.Writer) error {
+	w := writer.New(to)
+	w.Str("SET @").Str(s.Name.Value).Str(" = ")
+	if s.Subquery != nil {
+		w.Str("(")
+		_ = s.Subquery.Print(w)
+		w.Str(")")
+	} else {
+		w.Str(s.Value.Value)
+	}
+	return w.Err()
+}
@@ -22,6 +22,7 @@ const (
 	Release
 	BeginTransaction
 	Commit
+	Rollback
 )
 
 //A SQL statement or subquery
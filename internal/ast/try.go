@@ -0,0 +1,29 @@
+package ast
+
+import (
+	"io"
+
+	"github.com/jimmyfrasche/etlite/internal/ast/internal/writer"
+	"github.com/jimmyfrasche/etlite/internal/token"
+)
+
+//Try runs Body and, if it fails, undoes it and runs Catch.
+type Try struct {
+	token.Position
+	Body, Catch []Node
+}
+
+var _ Node = (*Try)(nil)
+
+func (*Try) node() {}
+
+//Print stringifies to a writer.
+func (n *Try) Print(to io.Writer) error {
+	w := writer.New(to)
+	w.Str("TRY").Nl()
+	printBlock(w, n.Body)
+	w.Str("CATCH").Nl()
+	printBlock(w, n.Catch)
+	w.Str("END")
+	return w.Err()
+}
@@ -20,7 +20,8 @@ type compiler struct {
 
 	dname, frname string
 	used          map[string]bool
-	hdr           []string
+	hdr, types    []string
+	source        bool //whether the input device adds virt.SourceColumn
 
 	inst []virt.Instruction
 
@@ -50,8 +51,23 @@ func (c *compiler) nameUsed(nm string) bool {
 	return c.used[strings.ToLower(nm)]
 }
 
-//Nodes collects and compiles the nodes on from into instructions for our VM.
-func Nodes(from <-chan ast.Node, usedStdin bool) (db string, to []virt.Instruction, err error) {
+//Compiler compiles nodes into instructions for our VM
+//one batch at a time.
+//
+//The state of each batch,
+//such as the current device, format, and any open transaction,
+//carries over to the next.
+type Compiler struct {
+	*compiler
+
+	firstStatement bool
+}
+
+//New creates a Compiler.
+//
+//If usedStdin, stdin is the source of the script
+//and cannot be used as an input device.
+func New(usedStdin bool) *Compiler {
 	c := &compiler{
 		inst:      make([]virt.Instruction, 0, 128),
 		usedStdin: usedStdin,
@@ -65,6 +81,34 @@ func Nodes(from <-chan ast.Node, usedStdin bool) (db string, to []virt.Instructi
 		c.dname = "-"
 	}
 
+	return &Compiler{
+		compiler:       c,
+		firstStatement: true,
+	}
+}
+
+//Nodes collects and compiles the nodes on from into instructions for our VM.
+func Nodes(from <-chan ast.Node, usedStdin bool) (db string, to []virt.Instruction, err error) {
+	c := New(usedStdin)
+	db, to, err = c.Compile(from)
+	if err != nil {
+		return "", nil, err
+	}
+	return db, append(to, c.Finish()...), nil
+}
+
+//Compile collects and compiles the nodes on from into instructions for our VM.
+//
+//Any transaction or savepoint left open remains open.
+//
+//If db is not empty, the batch began with a USE statement
+//and it is the name of the database to use.
+func (c *Compiler) Compile(from <-chan ast.Node) (db string, to []virt.Instruction, err error) {
+	c.inst = c.inst[:0]
+
+	//a batch that fails to compile is never run,
+	//so none of its statements may affect the next batch
+	p := c.checkpoint()
 	defer func() {
 		if x := recover(); x != nil {
 			e, ok := x.(error)
@@ -75,40 +119,92 @@ func Nodes(from <-chan ast.Node, usedStdin bool) (db string, to []virt.Instructi
 			} else {
 				panic(x)
 			}
-			err = e
+			c.restore(p)
+			db, to, err = "", nil, e
 		}
 	}()
 
-	firstStatement := true
 	for n := range from {
-		switch n := n.(type) {
-		default:
-			return "", nil, errint.Newf("internal error: unknown node type %T", n)
+		if d := c.compileNode(n); d != "" {
+			db = d
+		}
+		c.firstStatement = false
+	}
 
-		case *ast.Error:
-			return "", nil, n
+	return db, c.copyInst(), nil
+}
 
-		case *ast.Use:
-			if !firstStatement {
-				return "", nil, errusr.New(n, "USE must be first statement")
-			}
-			db = n.DB
+//compileNode compiles a single statement.
+//If it is a USE statement, the name of the database is returned.
+func (c *Compiler) compileNode(n ast.Node) (db string) {
+	switch n := n.(type) {
+	default:
+		panic(errint.Newf("internal error: unknown node type %T", n))
 
-		case *ast.Assert:
-			c.compileAssert(n)
+	case *ast.Error:
+		panic(n)
 
-		case *ast.Display:
-			c.compileDisplay(n)
+	case *ast.Use:
+		if !c.firstStatement {
+			panic(errusr.New(n, "USE must be first statement"))
+		}
+		return n.DB
 
-		case *ast.Import:
-			c.compileImport(n)
+	case *ast.Assert:
+		c.push(virt.Statement("Assert", n))
+		c.compileAssert(n)
 
-		case *ast.SQL:
-			c.compileSQL(n)
-		}
+	case *ast.Display:
+		c.push(virt.Statement("Display", n))
+		c.compileDisplay(n)
+
+	case *ast.Import:
+		c.push(virt.Statement("Import", n))
+		c.compileImport(n)
+
+	case *ast.SQL:
+		c.push(virt.Statement(statementKind(n), n))
+		c.compileSQL(n)
 
-		firstStatement = false
+	case *ast.SetTimeout:
+		c.push(virt.Statement("Set", n))
+		c.push(virt.ErrPos(n))
+		c.push(virt.SetTimeout(n.Timeout))
+
+	case *ast.SetVar:
+		c.push(virt.Statement("Set", n))
+		c.compileSetVar(n)
+
+	case *ast.If:
+		c.push(virt.Statement("If", n))
+		c.compileIf(n)
+
+	case *ast.ForEach:
+		c.push(virt.Statement("ForEach", n))
+		c.compileForEach(n)
+
+	case *ast.Try:
+		c.push(virt.Statement("Try", n))
+		c.compileTry(n)
 	}
+	return ""
+}
+
+//statementKind reports the kind of s for statistics.
+func statementKind(s *ast.SQL) string {
+	switch s.Kind {
+	case ast.Query:
+		return "Query"
+	case ast.CreateTableFrom, ast.InsertUsing:
+		return "Import"
+	}
+	return "Exec"
+}
+
+//Finish returns the instructions that close
+//any transaction or savepoint left open.
+func (c *Compiler) Finish() []virt.Instruction {
+	c.inst = c.inst[:0]
 
 	if c.stack.Open() {
 		c.push(virt.ErrPos(token.Position{
@@ -121,6 +217,48 @@ func Nodes(from <-chan ast.Node, usedStdin bool) (db string, to []virt.Instructi
 	} else if c.stack.HasSavepoints() {
 		c.push(virt.Exec("RELEASE " + c.stack.Top() + ";"))
 	}
+	c.stack = savepoint.New()
+
+	return c.copyInst()
+}
+
+//checkpoint is the state of a Compiler that carries over between batches.
+type checkpoint struct {
+	input          inputState
+	stack          *savepoint.Stack
+	used           map[string]bool
+	firstStatement bool
+}
+
+func (c *Compiler) checkpoint() checkpoint {
+	used := make(map[string]bool, len(c.used))
+	for k, v := range c.used {
+		used[k] = v
+	}
+	return checkpoint{
+		input:          c.input(),
+		stack:          c.stack.Copy(),
+		used:           used,
+		firstStatement: c.firstStatement,
+	}
+}
+
+//restore the state of the Compiler at p.
+func (c *Compiler) restore(p checkpoint) {
+	c.setInput(p.input)
+	c.stack = p.stack
+	c.used = p.used
+	c.firstStatement = p.firstStatement
+}
+
+//Rollback informs the compiler that the VM has rolled back
+//any open transaction or savepoint after a failure.
+func (c *Compiler) Rollback() {
+	c.stack = savepoint.New()
+}
 
-	return db, c.inst, nil
+//copyInst returns a copy of the compiled instructions,
+//so that the buffer can be reused by the next batch.
+func (c *compiler) copyInst() []virt.Instruction {
+	return append([]virt.Instruction(nil), c.inst...)
 }
@@ -0,0 +1,30 @@
+package compile
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jimmyfrasche/etlite/internal/ast"
+	"github.com/jimmyfrasche/etlite/internal/lex"
+	"github.com/jimmyfrasche/etlite/internal/parse"
+)
+
+//nodes parses the script src.
+func nodes(src string) <-chan ast.Node {
+	return parse.Tokens(lex.Stream("test", strings.NewReader(src)))
+}
+
+//TestCompileErrorRestores tests that a batch that fails to compile
+//does not change the state carried over to the next batch.
+func TestCompileErrorRestores(t *testing.T) {
+	c := New(false)
+	if _, _, err := c.Compile(nodes(`SAVEPOINT a; USE "y.db";`)); err == nil {
+		t.Fatal("expected USE after the first statement to fail")
+	}
+	if _, _, err := c.Compile(nodes("SELECT 1;")); err != nil {
+		t.Fatal("could not compile the next batch, got:", err)
+	}
+	if is := c.Finish(); len(is) != 0 {
+		t.Fatalf("expected no savepoint left open, got: %v", is)
+	}
+}
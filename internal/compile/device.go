@@ -6,7 +6,9 @@ import (
 	"unicode"
 
 	"github.com/jimmyfrasche/etlite/internal/ast"
+	"github.com/jimmyfrasche/etlite/internal/device/file"
 	"github.com/jimmyfrasche/etlite/internal/internal/errint"
+	"github.com/jimmyfrasche/etlite/internal/internal/errusr"
 	"github.com/jimmyfrasche/etlite/internal/virt"
 )
 
@@ -16,7 +18,7 @@ const (
 )
 
 func normFilename(nm string) string {
-	base := filepath.Base(nm)
+	base := filepath.Base(file.TrimExt(nm))
 	idx := strings.LastIndexByte(base, '.')
 	switch {
 	case idx < 0:
@@ -36,6 +38,36 @@ func normFilename(nm string) string {
 	})
 }
 
+var compressions = map[ast.Compression]file.Compression{
+	ast.DetectCompression: file.Detect,
+	ast.NoCompression:     file.None,
+	ast.Gzip:              file.Gzip,
+	ast.Bzip2:             file.Bzip2,
+	ast.XZ:                file.XZ,
+	ast.Zstd:              file.Zstd,
+}
+
+func compression(d ast.Device, c ast.Compression, name string, read bool) file.Compression {
+	comp, ok := compressions[c]
+	if !ok {
+		panic(errint.Newf("unrecognized compression: %s", c))
+	}
+	if !read && (comp == file.Bzip2 || comp == file.Detect && file.ForName(name) == file.Bzip2) {
+		panic(errusr.New(d, "BZIP2 compression is only supported for reading"))
+	}
+	return comp
+}
+
+//globName derives a name from a FILES pattern.
+//If the last element of the pattern matches many files,
+//the name is derived from its directory.
+func globName(pattern string) string {
+	if strings.ContainsAny(filepath.Base(pattern), `*?[\`) {
+		pattern = filepath.Dir(pattern)
+	}
+	return normFilename(pattern)
+}
+
 func (c *compiler) derivedDeviceName(nm string) {
 	c.frname = "" //old frame invalid on new device
 	c.dname = nm
@@ -53,6 +85,7 @@ func (c *compiler) compileDevice(d ast.Device, read bool) {
 
 	case *ast.DeviceStdio:
 		if read {
+			c.source = false
 			c.derivedDeviceName("-")
 			c.push(virt.UseStdin())
 		} else {
@@ -60,16 +93,61 @@ func (c *compiler) compileDevice(d ast.Device, read bool) {
 		}
 
 	case *ast.DeviceFile:
+		if d.NameExpr != nil {
+			q := "SELECT " + c.rewrite(d.NameExpr, nil, false)
+			comp := compression(d, d.Compression, "", read)
+			if read {
+				c.source = false
+				//the name is not known until the script is run
+				c.derivedDeviceName("")
+				c.push(virt.UseFileInputFrom(q, comp))
+			} else {
+				c.push(virt.UseFileOutputFrom(q, comp))
+			}
+			break
+		}
 		name, ok := d.Name.Unescape()
 		if !ok {
 			panic(errint.Newf("file device name must be literal or string got %s", d.Name.Kind))
 		}
+		comp := compression(d, d.Compression, name, read)
 		if read {
+			c.source = false
 			c.derivedDeviceName(normFilename(name))
-			c.push(virt.UseFileInput(name))
+			c.push(virt.UseFileInput(name, comp))
 		} else {
-			c.push(virt.UseFileOutput(name))
+			c.push(virt.UseFileOutput(name, comp))
+		}
+
+	case *ast.DeviceCommand:
+		cmd, ok := d.Command.Unescape()
+		if !ok {
+			panic(errint.Newf("command device must be literal or string got %s", d.Command.Kind))
+		}
+		if read {
+			c.source = false
+			//there is no reasonable name to derive from a command
+			c.derivedDeviceName("")
+			c.push(virt.UseCommandInput(cmd))
+		} else {
+			c.push(virt.UseCommandOutput(cmd))
+		}
+
+	case *ast.DeviceFiles:
+		pattern, ok := d.Pattern.Unescape()
+		if !ok {
+			panic(errint.Newf("files device pattern must be literal or string got %s", d.Pattern.Kind))
+		}
+		if !read {
+			panic(errint.New("files device used for output"))
+		}
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			panic(errusr.Wrap(d, err))
 		}
+		comp := compression(d, d.Compression, pattern, read)
+		c.source = d.Source
+		c.derivedDeviceName(globName(pattern))
+		c.push(virt.UseFilesInput(pattern, comp, d.Source))
 	}
 	if read {
 		c.hadDevice = true
@@ -0,0 +1,24 @@
+package compile
+
+import (
+	"github.com/jimmyfrasche/etlite/internal/ast"
+	"github.com/jimmyfrasche/etlite/internal/virt"
+)
+
+func (c *Compiler) compileForEach(n *ast.ForEach) {
+	c.push(virt.ErrPos(n))
+	q := c.rewrite(n.Query, nil, false)
+	c.firstStatement = false
+	stack := c.stack.Copy()
+	before := c.input()
+
+	c.push(virt.ForEach(q))
+	next := len(c.inst)
+	c.push(virt.Next(-1)) //patched once the body is compiled
+	c.compileBlock(n, "the body of FOR EACH ROW", n.Body, stack)
+	c.push(virt.Jump(next))
+	c.inst[next] = virt.Next(len(c.inst))
+
+	//the body may run any number of times
+	c.setInput(before.merge(c.input()))
+}
@@ -3,7 +3,10 @@ package compile
 import (
 	"github.com/jimmyfrasche/etlite/internal/ast"
 	"github.com/jimmyfrasche/etlite/internal/format/csvfmt"
+	"github.com/jimmyfrasche/etlite/internal/format/jsonfmt"
+	"github.com/jimmyfrasche/etlite/internal/format/ndjsonfmt"
 	"github.com/jimmyfrasche/etlite/internal/format/rawfmt"
+	"github.com/jimmyfrasche/etlite/internal/format/xlsxfmt"
 	"github.com/jimmyfrasche/etlite/internal/internal/eol"
 	"github.com/jimmyfrasche/etlite/internal/internal/errint"
 	"github.com/jimmyfrasche/etlite/internal/internal/errusr"
@@ -16,14 +19,15 @@ const (
 )
 
 func (c *compiler) compileFormat(f ast.Format, read bool) {
+	if f == nil {
+		return
+	}
+
 	c.push(virt.ErrPos(f))
 	switch f := f.(type) {
 	default:
 		panic(errint.Newf("unrecognized Format type: %T", f))
 
-	case nil:
-		return
-
 	case *ast.FormatCSV:
 		c.formatCSV(f, read)
 
@@ -32,15 +36,35 @@ func (c *compiler) compileFormat(f ast.Format, read bool) {
 
 	case *ast.FormatJSON:
 		c.formatJSON(f, read)
+
+	case *ast.FormatNDJSON:
+		c.formatNDJSON(f, read)
+
+	case *ast.FormatXLSX:
+		c.formatXLSX(f, read)
 	}
 }
 
 func (c *compiler) formatCSV(f *ast.FormatCSV, read bool) {
-	if f.Quote > 0 { //XXX unsupported currently since encoding/csv doesn't do that
-		panic(errusr.New(f, "specifying quotation for CSV is currently unsupported :("))
+	if !read && (f.Comment > 0 || f.Trim) {
+		panic(errusr.New(f, "COMMENT and TRIM only apply when reading CSV"))
 	}
-	if f.Line != 0 && !read {
-		panic(errusr.New(f, "specifying line ending when writing CSV is unsupported"))
+	checkCSVRunes(f)
+
+	if read { //decoder
+		//either line ending is accepted when reading
+		d := &csvfmt.Decoder{
+			Null:             f.Null,
+			Quote:            f.Quote,
+			Escape:           f.Escape,
+			Comma:            f.Delim,
+			Comment:          f.Comment,
+			TrimLeadingSpace: f.Trim,
+			Strict:           f.Strict,
+			NoHeader:         f.NoHeader,
+		}
+		c.push(virt.SetDecoder(d))
+		return
 	}
 
 	useCRLF := false
@@ -55,24 +79,48 @@ func (c *compiler) formatCSV(f *ast.FormatCSV, read bool) {
 		useCRLF = false
 	}
 
-	if read { //decoder
-		d := &csvfmt.Decoder{
-			Null:     f.Null,
-			Quote:    f.Quote,
-			Comma:    f.Delim,
-			NoHeader: f.NoHeader,
-			UseCRLF:  useCRLF,
+	e := &csvfmt.Encoder{
+		Null:     f.Null,
+		Quote:    f.Quote,
+		Escape:   f.Escape,
+		Comma:    f.Delim,
+		NoHeader: f.NoHeader,
+		UseCRLF:  useCRLF,
+	}
+	c.push(virt.SetEncoder(e))
+}
+
+//checkCSVRunes ensures that the special runes of a CSV format are unambiguous.
+func checkCSVRunes(f *ast.FormatCSV) {
+	delim, quote := f.Delim, f.Quote
+	if delim <= 0 {
+		delim = ','
+	}
+	if quote <= 0 {
+		quote = '"'
+	}
+	runes := []struct {
+		name string
+		r    rune
+	}{
+		{"DELIMITER", delim},
+		{"QUOTE", quote},
+		{"ESCAPE", f.Escape},
+		{"COMMENT", f.Comment},
+	}
+	for i, a := range runes {
+		if a.r <= 0 {
+			continue
 		}
-		c.push(virt.SetDecoder(d))
-	} else { //encoder
-		e := &csvfmt.Encoder{
-			Null:     f.Null,
-			Quote:    f.Quote,
-			Comma:    f.Delim,
-			NoHeader: f.NoHeader,
-			UseCRLF:  useCRLF,
+		if a.r == '\r' || a.r == '\n' {
+			panic(errusr.Newf(f, "CSV %s cannot be a line ending", a.name))
+		}
+		for _, b := range runes[i+1:] {
+			//an escape that is the quote is the same as doubling quotes, the default
+			if a.r == b.r && !(a.name == "QUOTE" && b.name == "ESCAPE") {
+				panic(errusr.Newf(f, "CSV %s and %s cannot both be %q", a.name, b.name, a.r))
+			}
 		}
-		c.push(virt.SetEncoder(e))
 	}
 }
 
@@ -110,5 +158,42 @@ func (c *compiler) formatRaw(f *ast.FormatRaw, read bool) {
 }
 
 func (c *compiler) formatJSON(f *ast.FormatJSON, read bool) {
-	panic(errint.New("json not implemented yet"))
+	if read { //decoder
+		d := &jsonfmt.Decoder{
+			Strict: f.Strict,
+		}
+		c.push(virt.SetDecoder(d))
+	} else { //encoder
+		e := &jsonfmt.Encoder{
+			Arrays: f.Arrays,
+		}
+		c.push(virt.SetEncoder(e))
+	}
+}
+
+func (c *compiler) formatNDJSON(f *ast.FormatNDJSON, read bool) {
+	if read { //decoder
+		d := &ndjsonfmt.Decoder{
+			Strict: f.Strict,
+			Sample: f.Sample,
+		}
+		c.push(virt.SetDecoder(d))
+	} else { //encoder
+		c.push(virt.SetEncoder(&ndjsonfmt.Encoder{}))
+	}
+}
+
+func (c *compiler) formatXLSX(f *ast.FormatXLSX, read bool) {
+	if read { //decoder
+		d := &xlsxfmt.Decoder{
+			Strict:   f.Strict,
+			NoHeader: f.NoHeader,
+		}
+		c.push(virt.SetDecoder(d))
+	} else { //encoder
+		if f.NoHeader {
+			panic(errusr.New(f, "NOHEADER is not supported when writing XLSX"))
+		}
+		c.push(virt.SetEncoder(&xlsxfmt.Encoder{}))
+	}
 }
@@ -0,0 +1,103 @@
+package compile
+
+import (
+	"github.com/jimmyfrasche/etlite/internal/ast"
+	"github.com/jimmyfrasche/etlite/internal/internal/errusr"
+	"github.com/jimmyfrasche/etlite/internal/internal/savepoint"
+	"github.com/jimmyfrasche/etlite/internal/virt"
+)
+
+//inputState is what the compiler knows about the input device and format,
+//which may differ depending on the branch taken by an IF.
+type inputState struct {
+	hadDevice, source bool
+	dname, frname     string
+	hdr, types        []string
+}
+
+func (c *compiler) input() inputState {
+	return inputState{
+		hadDevice: c.hadDevice,
+		source:    c.source,
+		dname:     c.dname,
+		frname:    c.frname,
+		hdr:       c.hdr,
+		types:     c.types,
+	}
+}
+
+func (c *compiler) setInput(s inputState) {
+	c.hadDevice, c.source = s.hadDevice, s.source
+	c.dname, c.frname = s.dname, s.frname
+	c.hdr, c.types = s.hdr, s.types
+}
+
+//merge the states of the two branches of an IF,
+//forgetting anything they disagree on.
+func (s inputState) merge(t inputState) inputState {
+	m := inputState{
+		hadDevice: s.hadDevice && t.hadDevice,
+		source:    s.source && t.source,
+	}
+	if s.dname == t.dname {
+		m.dname = s.dname
+	}
+	if s.frname == t.frname {
+		m.frname = s.frname
+	}
+	if sameStrings(s.hdr, t.hdr) && sameStrings(s.types, t.types) {
+		m.hdr, m.types = s.hdr, s.types
+	}
+	return m
+}
+
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Compiler) compileIf(n *ast.If) {
+	c.push(virt.ErrPos(n))
+	cond := c.rewrite(n.Cond, nil, false)
+	c.firstStatement = false
+	stack := c.stack.Copy()
+	before := c.input()
+
+	//the targets of the jumps are patched in once the branches are compiled
+	branch := len(c.inst)
+	c.push(virt.JumpUnless(cond, -1))
+	c.compileBlock(n, "a branch of IF", n.Then, stack)
+	after := c.input()
+
+	if len(n.Else) == 0 {
+		c.inst[branch] = virt.JumpUnless(cond, len(c.inst))
+		c.setInput(after.merge(before))
+		return
+	}
+
+	end := len(c.inst)
+	c.push(virt.Jump(-1))
+	c.inst[branch] = virt.JumpUnless(cond, len(c.inst))
+	c.setInput(before)
+	c.compileBlock(n, "a branch of IF", n.Else, stack)
+	c.inst[end] = virt.Jump(len(c.inst))
+	c.setInput(after.merge(c.input()))
+}
+
+//compileBlock compiles the statements in the body of n,
+//which must leave the savepoint stack as it found it.
+func (c *Compiler) compileBlock(n ast.Node, what string, body []ast.Node, stack *savepoint.Stack) {
+	for _, s := range body {
+		c.compileNode(s)
+	}
+	if !c.stack.Equal(stack) {
+		panic(errusr.Newf(n, "%s cannot end a transaction or savepoint begun outside of it or leave one open", what))
+	}
+}
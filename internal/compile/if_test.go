@@ -0,0 +1,78 @@
+package compile
+
+import (
+	"testing"
+
+	"github.com/jimmyfrasche/etlite/internal/virt"
+)
+
+//target returns the instruction jumped to by i.
+func target(t *testing.T, i virt.Instruction) int {
+	for _, o := range i.Operands {
+		if o.Name == "to" {
+			return o.Value.(int)
+		}
+	}
+	t.Fatalf("%s has no target", i)
+	return 0
+}
+
+//queryAt returns the first query run starting at instruction n,
+//following any unconditional jumps, or "" if there is none.
+func queryAt(t *testing.T, is []virt.Instruction, n int) string {
+	for n < len(is) {
+		switch i := is[n]; i.Op {
+		case virt.OpQuery:
+			return i.Operands[0].Value.(string)
+		case virt.OpJump:
+			n = target(t, i)
+		default:
+			n++
+		}
+	}
+	return ""
+}
+
+//TestIfJumps tests that the jumps of an IF go to the start of
+//the branch not taken or to the end of the IF.
+func TestIfJumps(t *testing.T) {
+	for _, test := range []struct {
+		src string
+		to  []string //first query at the target of each jump, in order
+	}{
+		{
+			src: "IF (SELECT 1) THEN SELECT 'a'; END IF; SELECT 'c';",
+			to:  []string{"SELECT'c';"},
+		},
+		{
+			src: "IF (SELECT 1) THEN SELECT 'a'; ELSE SELECT 'b'; END IF; SELECT 'c';",
+			to:  []string{"SELECT'b';", "SELECT'c';"},
+		},
+		{
+			src: "IF (SELECT 1) THEN SELECT 'a'; SELECT 'a2'; ELSE SELECT 'b'; END IF;",
+			to:  []string{"SELECT'b';", ""},
+		},
+		{
+			src: "IF (SELECT 1) THEN IF (SELECT 0) THEN SELECT 'a'; ELSE SELECT 'b'; END IF; ELSE SELECT 'c'; END IF; SELECT 'd';",
+			to:  []string{"SELECT'c';", "SELECT'b';", "SELECT'd';", "SELECT'd';"},
+		},
+	} {
+		_, is, err := Nodes(nodes(test.src), false)
+		if err != nil {
+			t.Fatalf("%s: could not compile, got: %s", test.src, err)
+		}
+		var got []string
+		for _, i := range is {
+			if i.Op == virt.OpJump || i.Op == virt.OpJumpUnless {
+				n := target(t, i)
+				if n < 0 || n > len(is) {
+					t.Fatalf("%s: %s jumps outside of the program", test.src, i)
+				}
+				got = append(got, queryAt(t, is, n))
+			}
+		}
+		if !sameStrings(got, test.to) {
+			t.Errorf("%s: expected jumps to %q got %q", test.src, test.to, got)
+		}
+	}
+}
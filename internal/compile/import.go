@@ -25,6 +25,9 @@ func (c *compiler) compileCreateTableAsImport(nm string, s *ast.SQL) {
 	if len(imp.Header) != 0 {
 		panic(errusr.New(imp, "illegal to specify header in CREATE TABLE FROM IMPORT"))
 	}
+	if imp.InferTypes > 0 {
+		panic(errusr.New(imp, "illegal to specify INFER TYPES in CREATE TABLE FROM IMPORT"))
+	}
 
 	c.push(virt.Savepoint())
 
@@ -35,8 +38,11 @@ func (c *compiler) compileCreateTableAsImport(nm string, s *ast.SQL) {
 	hdr := colsOf(s)
 	imp.Header = hdr
 	c.compileImportCommon(imp)
+	if c.source {
+		panic(errusr.New(imp, "illegal to import FILES with SOURCE in CREATE TABLE FROM IMPORT"))
+	}
 
-	ins := synth.Insert(nm, hdr)
+	ins := synth.Insert(imp.Conflict, nm, hdr)
 	c.push(virt.InsertWith(nm, imp.Frame, ins, hdr, imp.Limit, imp.Offset))
 	c.push(virt.Release())
 }
@@ -54,12 +60,21 @@ func (c *compiler) compileInsertUsing(nm string, s *ast.SQL) {
 	if len(imp.Header) != 0 {
 		panic(errusr.New(imp, "illegal to specify header in INSERT USING IMPORT"))
 	}
+	if imp.InferTypes > 0 {
+		panic(errusr.New(imp, "illegal to specify INFER TYPES in INSERT USING IMPORT"))
+	}
+	if imp.Conflict != "" {
+		panic(errusr.New(imp, "illegal to specify ON CONFLICT in INSERT USING IMPORT: use INSERT OR "+imp.Conflict))
+	}
 
 	c.push(virt.Savepoint())
 
 	hdr := colsOf(s)
 	imp.Header = hdr
 	c.compileImportCommon(imp)
+	if c.source {
+		panic(errusr.New(imp, "illegal to import FILES with SOURCE in INSERT USING IMPORT"))
+	}
 
 	//serialize insert statement and add VALUES (?, ..., ?);
 	q := c.rewrite(s, nil, false)
@@ -82,29 +97,32 @@ func (c *compiler) compileSubImport(i *ast.Import, tbl string) {
 	i.Temporary = true
 
 	c.compileImportCommon(i)
-	if len(i.Header) == 0 {
-		c.push(virt.Import(true, tbl, i.Frame, i.Limit, i.Offset))
-	} else {
-		c.compileImportStatic(i)
-	}
+	c.compileImportInto(i, tbl)
 }
 
 func (c *compiler) compileImport(i *ast.Import) {
 	c.push(virt.Savepoint())
 	c.compileImportCommon(i)
-	if len(i.Header) == 0 {
-		c.push(virt.Import(i.Temporary, i.Name.String(), i.Frame, i.Limit, i.Offset))
-	} else {
-		c.compileImportStatic(i)
-	}
+	c.compileImportInto(i, i.Name.String())
 	c.push(virt.Release())
 }
 
-func (c *compiler) compileImportStatic(i *ast.Import) {
-	tbl := i.Name.String()
-	ddl := synth.CreateTable(i.Temporary, tbl, i.Header)
+//compileImportInto creates tbl and imports into it.
+//If the header is known and no types need to be inferred,
+//the table is created statically.
+func (c *compiler) compileImportInto(i *ast.Import, tbl string) {
+	if len(i.Header) == 0 || i.InferTypes > 0 {
+		c.push(virt.Import(i.Temporary, tbl, i.Frame, i.Header, i.Types, i.InferTypes, i.Limit, i.Offset, i.Conflict))
+		return
+	}
+
+	cols := i.Header
+	if c.source {
+		cols = append(cols[:len(cols):len(cols)], virt.SourceColumn)
+	}
+	ddl := synth.CreateTable(i.Temporary, tbl, cols, i.Types)
 	c.push(virt.Exec(ddl))
-	ins := synth.Insert(tbl, i.Header)
+	ins := synth.Insert(i.Conflict, tbl, cols)
 	c.push(virt.InsertWith(tbl, i.Frame, ins, i.Header, i.Limit, i.Offset))
 }
 
@@ -132,12 +150,12 @@ func (c *compiler) compileImportCommon(i *ast.Import) {
 
 	//header propagation
 	if i.Device != nil || i.Format != nil || i.Frame != "" {
-		c.hdr = nil
+		c.hdr, c.types = nil, nil
 	}
 	if len(i.Header) == 0 {
-		i.Header = c.hdr
+		i.Header, i.Types = c.hdr, c.types
 	} else {
-		c.hdr = i.Header
+		c.hdr, c.types = i.Header, i.Types
 	}
 
 	if i.Frame == "" {
@@ -149,12 +167,13 @@ func (c *compiler) compileImportCommon(i *ast.Import) {
 	}
 
 	if i.Name.Empty() {
-		if c.dname != "" && !c.nameUsed(c.dname) {
-			c.rec(c.dname)
-			i.Name = ast.NameFromString(c.dname)
-		} else if c.frname != "" && !c.nameUsed(c.frname) {
+		//a frame names a table in the device, so it is more specific than the device name
+		if c.frname != "" && !c.nameUsed(c.frname) {
 			c.rec(c.frname)
 			i.Name = ast.NameFromString(c.frname)
+		} else if c.dname != "" && !c.nameUsed(c.dname) {
+			c.rec(c.dname)
+			i.Name = ast.NameFromString(c.dname)
 		} else {
 			panic(errusr.New(i, "cannot derive table name"))
 		}
@@ -166,4 +185,9 @@ func (c *compiler) compileImportCommon(i *ast.Import) {
 	}
 
 	c.push(virt.ErrPos(i))
+
+	//REJECTS without MAX ERRORS rejects any number of rows
+	if i.MaxErrors >= 0 || i.Rejects != "" {
+		c.push(virt.SetRejects(i.MaxErrors, i.Rejects))
+	}
 }
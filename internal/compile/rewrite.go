@@ -19,6 +19,27 @@ func parseArg(t token.Value) (s string, isNum bool) {
 	return
 }
 
+//argQuery returns a query selecting the value of the @ argument t,
+//which is a command line argument if it is a number,
+//and otherwise a script variable, if one has been set,
+//or an environment variable.
+//
+//Script variables are looked up when the query is run
+//since FOR EACH ROW binds variables named by its columns.
+func (c *compiler) argQuery(t token.Value) string {
+	s, isNum := parseArg(t)
+	if isNum {
+		return "SELECT value FROM sys.args WHERE rowid=" + s
+	}
+	//a script variable set to NULL still hides the environment variable
+	return strings.Join([]string{
+		"SELECT value FROM (",
+		"SELECT 0 AS k, value FROM sys.vars WHERE name=", s,
+		" UNION ALL SELECT 1, value FROM sys.env WHERE name=", s,
+		") ORDER BY k LIMIT 1",
+	}, "")
+}
+
 func (c *compiler) appendSynth(qp string) {
 	c.r.Tokens = append(c.r.Tokens, token.Value{
 		Kind:  token.Literal,
@@ -47,12 +68,7 @@ func (c *compiler) rewrite(s *ast.SQL, tables []string, noArg bool) string {
 			if noArg {
 				panic(errint.Newf("expected no arguments in %#v", s))
 			}
-			s, isNum := parseArg(t)
-			q := []string{"(SELECT value FROM sys.", "env", " WHERE ", "name", "=", s, ")"}
-			if isNum {
-				q[1], q[3] = "args", "rowid"
-			}
-			c.appendSynth(strings.Join(q, ""))
+			c.appendSynth("(" + c.argQuery(t) + ")")
 		default:
 			c.r.Tokens = append(c.r.Tokens, t)
 		}
@@ -0,0 +1,19 @@
+package compile
+
+import (
+	"github.com/jimmyfrasche/etlite/internal/ast"
+	"github.com/jimmyfrasche/etlite/internal/internal/escape"
+	"github.com/jimmyfrasche/etlite/internal/virt"
+)
+
+func (c *compiler) compileSetVar(s *ast.SetVar) {
+	var expr string
+	if s.Subquery != nil {
+		expr = "(" + c.rewrite(s.Subquery, nil, false) + ")"
+	} else {
+		v, _ := s.Value.Unescape()
+		expr = escape.String(v)
+	}
+	c.push(virt.ErrPos(s))
+	c.push(virt.SetVar(s.Name.Value, expr))
+}
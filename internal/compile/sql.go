@@ -13,7 +13,7 @@ func (c *compiler) compileSQL(s *ast.SQL) {
 	switch s.Kind {
 	default:
 		panic(errint.Newf("got unknown or invalid sql kind %d", s.Kind))
-	case ast.Savepoint, ast.Release, ast.BeginTransaction, ast.Commit:
+	case ast.Savepoint, ast.Release, ast.BeginTransaction, ast.Commit, ast.Rollback:
 		if ls := len(s.Subqueries); ls != 0 {
 			panic(errint.Newf("%s cannot have etl subqueries, found %d", s.Kind, ls))
 		}
@@ -106,5 +106,18 @@ func (c *compiler) compileTransactor(s *ast.SQL) {
 			panic(errusr.Wrap(s, err))
 		}
 		c.push(virt.UserRelease(name, q))
+
+	case ast.Rollback:
+		if s.Name.Empty() {
+			if err := c.stack.Rollback(); err != nil {
+				panic(errusr.Wrap(s, err))
+			}
+			c.push(virt.Rollback(q))
+			break
+		}
+		if _, err := c.stack.RollbackTo(name); err != nil {
+			panic(errusr.Wrap(s, err))
+		}
+		c.push(virt.RollbackTo(name, q))
 	}
 }
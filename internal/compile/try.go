@@ -0,0 +1,33 @@
+package compile
+
+import (
+	"github.com/jimmyfrasche/etlite/internal/ast"
+	"github.com/jimmyfrasche/etlite/internal/internal/errint"
+	"github.com/jimmyfrasche/etlite/internal/virt"
+)
+
+func (c *Compiler) compileTry(n *ast.Try) {
+	c.push(virt.ErrPos(n))
+	c.firstStatement = false
+	stack := c.stack.Copy()
+	before := c.input()
+
+	//the targets of the jumps are patched in once the blocks are compiled
+	try := len(c.inst)
+	c.push(virt.Try(-1))
+	c.stack.Savepoint("2") //the name used by virt.Try
+	c.compileBlock(n, "the body of TRY", n.Body, c.stack.Copy())
+	if err := c.stack.Release("2"); err != nil {
+		panic(errint.Wrap(err))
+	}
+	end := len(c.inst)
+	c.push(virt.EndTry(-1))
+	c.inst[try] = virt.Try(len(c.inst))
+	after := c.input()
+
+	//the body may have failed at any point
+	c.setInput(before.merge(after))
+	c.compileBlock(n, "the body of CATCH", n.Catch, stack)
+	c.inst[end] = virt.EndTry(len(c.inst))
+	c.setInput(after.merge(c.input()))
+}
@@ -0,0 +1,205 @@
+//Package command implements devices that pipe to or from a subprocess.
+package command
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/jimmyfrasche/etlite/internal/device"
+	"github.com/jimmyfrasche/etlite/internal/device/std"
+	"github.com/jimmyfrasche/etlite/internal/internal/errsys"
+)
+
+func newCmd(command string) *exec.Cmd {
+	args := append(shell[1:len(shell):len(shell)], command)
+	cmd := exec.Command(shell[0], args...)
+	cmd.Stderr = os.Stderr
+	return cmd
+}
+
+//Reader reads the standard output of a command run by the shell.
+type Reader struct {
+	name string
+	cmd  *exec.Cmd
+	out  io.ReadCloser
+	done bool  //whether the command has been waited on
+	err  error //the result of waiting on the command
+	*bufio.Reader
+}
+
+var _ device.Reader = (*Reader)(nil)
+
+//NewReader starts command.
+//
+//Its standard input is empty and its standard error is shared
+//with this process.
+func NewReader(command string) (*Reader, error) {
+	cmd := newCmd(command)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, errsys.Wrap(err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, errsys.WrapWith(command+":", err)
+	}
+	r := &Reader{
+		name: command,
+		cmd:  cmd,
+		out:  out,
+	}
+	r.Reader = bufio.NewReader(stdout{r})
+	return r, nil
+}
+
+//stdout reads the output of the command,
+//reporting how the command exited in place of EOF.
+type stdout struct {
+	r *Reader
+}
+
+func (s stdout) Read(p []byte) (int, error) {
+	n, err := s.r.out.Read(p)
+	if err == io.EOF {
+		if werr := s.r.wait(); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+//wait for the command to exit.
+//The error is not a system error, as the decoder reading it wraps it.
+func (r *Reader) wait() error {
+	if !r.done {
+		r.done = true
+		r.err = r.exited(r.cmd.Wait())
+	}
+	return r.err
+}
+
+//exited records which command exited with err, if not nil.
+func (r *Reader) exited(err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("%s: %s", r.name, err)
+}
+
+//Name returns the command.
+func (r *Reader) Name() string {
+	return r.name
+}
+
+//Unwrap returns the underlying bufio.Reader.
+func (r *Reader) Unwrap() *bufio.Reader {
+	return r.Reader
+}
+
+//Close the output of the command and wait for it to exit.
+//
+//If the output was not read to the end,
+//the command exiting due to a broken pipe is not an error.
+func (r *Reader) Close() error {
+	var err error
+	if r.done {
+		err = r.err
+	} else {
+		_ = r.out.Close()
+		r.done = true
+		if werr := r.cmd.Wait(); !brokenPipe(werr) {
+			err = r.exited(werr)
+		}
+	}
+	err = errsys.Wrap(err)
+	r.name = "<BROKEN COMMAND HANDLE>"
+	r.Reader.Reset(nil)
+	r.Reader = nil
+	return err
+}
+
+//Writer writes to the standard input of a command run by the shell.
+type Writer struct {
+	name      string
+	cmd       *exec.Cmd
+	in        io.WriteCloser
+	cancelled bool
+	*bufio.Writer
+}
+
+var _ device.Writer = (*Writer)(nil)
+
+//NewWriter starts command.
+//
+//Its standard output and standard error are shared with this process.
+func NewWriter(command string) (*Writer, error) {
+	//anything already written to stdout must precede the output of command
+	if err := std.Out.Flush(); err != nil {
+		return nil, errsys.Wrap(err)
+	}
+	cmd := newCmd(command)
+	cmd.Stdout = os.Stdout
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, errsys.Wrap(err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, errsys.WrapWith(command+":", err)
+	}
+	return &Writer{
+		name:   command,
+		cmd:    cmd,
+		in:     in,
+		Writer: bufio.NewWriter(in),
+	}, nil
+}
+
+//Name returns the command.
+func (w *Writer) Name() string {
+	return w.name
+}
+
+//Cancel kills the command.
+func (w *Writer) Cancel() {
+	if w.cancelled {
+		return
+	}
+	w.cancelled = true
+	_ = w.cmd.Process.Kill()
+}
+
+//Unwrap returns the underlying bufio.Writer.
+func (w *Writer) Unwrap() *bufio.Writer {
+	return w.Writer
+}
+
+//Close flushes, closes the input of the command, and waits for it to exit.
+//
+//It is an error if the command exits unsuccessfully,
+//unless it has been cancelled.
+func (w *Writer) Close() error {
+	defer func() {
+		w.Writer.Reset(nil)
+		w.Writer = nil
+		w.name = "<BROKEN COMMAND HANDLE>"
+	}()
+
+	var err error
+	if !w.cancelled {
+		err = w.Flush()
+	}
+	if cerr := w.in.Close(); err == nil {
+		err = cerr
+	}
+	werr := w.cmd.Wait()
+	if w.cancelled {
+		return nil
+	}
+	//how the command exited is more informative than a broken pipe
+	if werr != nil {
+		return errsys.WrapWith(w.name+":", werr)
+	}
+	return errsys.WrapWith(w.name+":", err)
+}
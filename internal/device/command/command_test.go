@@ -0,0 +1,111 @@
+// +build !windows
+
+package command
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReader(t *testing.T) {
+	r, err := NewReader("printf 'a\\nb\\n'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(p) != "a\nb\n" {
+		t.Fatalf("got %q", p)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReaderFail(t *testing.T) {
+	r, err := NewReader("echo a; exit 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ioutil.ReadAll(r); err == nil {
+		t.Fatal("expected error from exit status")
+	}
+	if err := r.Close(); err == nil {
+		t.Fatal("expected error from exit status on close")
+	}
+}
+
+func TestReaderEarlyClose(t *testing.T) {
+	r, err := NewReader("yes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.ReadString('\n'); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func tmpfile(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "etlite-command")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "out"), func() { os.RemoveAll(dir) }
+}
+
+func TestWriter(t *testing.T) {
+	out, cleanup := tmpfile(t)
+	defer cleanup()
+
+	w, err := NewWriter("sort -u > " + out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.WriteString("b\na\nb\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	p, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(p) != "a\nb\n" {
+		t.Fatalf("got %q", p)
+	}
+}
+
+func TestWriterFail(t *testing.T) {
+	w, err := NewWriter("cat > /dev/null; exit 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err == nil {
+		t.Fatal("expected error from exit status")
+	}
+}
+
+func TestWriterCancel(t *testing.T) {
+	out, cleanup := tmpfile(t)
+	defer cleanup()
+
+	w, err := NewWriter("cat > /dev/null && touch " + out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Cancel()
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Fatal("expected command to be killed")
+	}
+}
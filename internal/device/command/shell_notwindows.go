@@ -0,0 +1,31 @@
+// +build !windows
+
+package command
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+var shell = []string{"sh", "-c"}
+
+//brokenPipe reports whether err is nil or
+//the command was killed by writing to a closed pipe,
+//either directly or as reported by the shell.
+func brokenPipe(err error) bool {
+	if err == nil {
+		return true
+	}
+	e, ok := err.(*exec.ExitError)
+	if !ok {
+		return false
+	}
+	s, ok := e.Sys().(syscall.WaitStatus)
+	if !ok {
+		return false
+	}
+	if s.Signaled() {
+		return s.Signal() == syscall.SIGPIPE
+	}
+	return s.ExitStatus() == 128+int(syscall.SIGPIPE)
+}
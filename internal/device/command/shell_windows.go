@@ -0,0 +1,11 @@
+// +build windows
+
+package command
+
+var shell = []string{"cmd", "/C"}
+
+//brokenPipe reports whether err is nil.
+//Windows does not signal a command writing to a closed pipe.
+func brokenPipe(err error) bool {
+	return err == nil
+}
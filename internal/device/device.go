@@ -5,6 +5,7 @@ package device
 
 import (
 	"bufio"
+	"errors"
 	"io"
 	"os"
 )
@@ -40,6 +41,13 @@ type Writer interface {
 //When done with the os.File, users of this interface must ensure the file
 //is in a good state for future reading and writing and then call the returned
 //reset function that ensures the device can function as a device.
+//
+//A device that is backed by a file that cannot be accessed directly,
+//such as a compressed file, returns ErrNotFile.
 type File interface {
 	File() (f *os.File, reset func(), err error)
 }
+
+//ErrNotFile is returned by File when the contents of the file
+//are not available from the file handle.
+var ErrNotFile = errors.New("device contents are not directly available as a file")
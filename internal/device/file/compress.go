@@ -0,0 +1,153 @@
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+//Compression is the compression of a file.
+type Compression int
+
+const (
+	//Detect the compression from the file extension
+	//or, when reading, from the first bytes of the file.
+	Detect Compression = iota
+	//None is an uncompressed file.
+	None
+	//Gzip is gzip compression.
+	Gzip
+	//Bzip2 is bzip2 compression. It is only supported for reading.
+	Bzip2
+	//XZ is xz compression.
+	XZ
+	//Zstd is Zstandard compression.
+	Zstd
+)
+
+func (c Compression) String() string {
+	switch c {
+	case Detect:
+		return "DETECT"
+	case None:
+		return "NONE"
+	case Gzip:
+		return "GZIP"
+	case Bzip2:
+		return "BZIP2"
+	case XZ:
+		return "XZ"
+	case Zstd:
+		return "ZSTD"
+	}
+	return "<UNKNOWN COMPRESSION>"
+}
+
+var errBzip2Write = errors.New("bzip2 compression is only supported for reading")
+
+var extensions = map[string]Compression{
+	".gz":   Gzip,
+	".gzip": Gzip,
+	".bz2":  Bzip2,
+	".xz":   XZ,
+	".zst":  Zstd,
+}
+
+var magics = []struct {
+	magic []byte
+	c     Compression
+}{
+	{[]byte{0x1f, 0x8b}, Gzip},
+	{[]byte("BZh"), Bzip2},
+	{[]byte{0xfd, '7', 'z', 'X', 'Z', 0x00}, XZ},
+	{[]byte{0x28, 0xb5, 0x2f, 0xfd}, Zstd},
+}
+
+//ForName reports the compression implied by the extension of name,
+//or None if the extension does not denote a compression.
+func ForName(name string) Compression {
+	if c, ok := extensions[strings.ToLower(filepath.Ext(name))]; ok {
+		return c
+	}
+	return None
+}
+
+//TrimExt removes the extension of name if it denotes a compression.
+func TrimExt(name string) string {
+	if ForName(name) == None {
+		return name
+	}
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+//detect the compression of the start of a file.
+func detect(r *bufio.Reader) Compression {
+	//an error just means there are not enough bytes for a match
+	p, _ := r.Peek(6)
+	for _, m := range magics {
+		if bytes.HasPrefix(p, m.magic) {
+			return m.c
+		}
+	}
+	return None
+}
+
+//decompress r with c.
+func decompress(r io.Reader, c Compression) (io.ReadCloser, error) {
+	switch c {
+	case Gzip:
+		z, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return z, nil
+	case Bzip2:
+		return ioutil.NopCloser(bzip2.NewReader(r)), nil
+	case XZ:
+		z, err := xz.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(z), nil
+	case Zstd:
+		z, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return z.IOReadCloser(), nil
+	}
+	return nil, fmt.Errorf("cannot decompress %s", c)
+}
+
+//compress w with c.
+func compress(w io.Writer, c Compression) (io.WriteCloser, error) {
+	switch c {
+	case Gzip:
+		return gzip.NewWriter(w), nil
+	case Bzip2:
+		return nil, errBzip2Write
+	case XZ:
+		z, err := xz.NewWriter(w)
+		if err != nil {
+			return nil, err
+		}
+		return z, nil
+	case Zstd:
+		z, err := zstd.NewWriter(w)
+		if err != nil {
+			return nil, err
+		}
+		return z, nil
+	}
+	return nil, fmt.Errorf("cannot compress %s", c)
+}
@@ -3,6 +3,7 @@ package file
 
 import (
 	"bufio"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -16,13 +17,15 @@ import (
 type Reader struct {
 	name string
 	f    *os.File
+	z    io.ReadCloser //decompresses f, if compressed
 	*bufio.Reader
 }
 
 var _ device.Reader = (*Reader)(nil)
 
-//NewReader attempts to open a file for reading.
-func NewReader(name string) (*Reader, error) {
+//NewReader attempts to open a file for reading,
+//decompressing it with c.
+func NewReader(name string, c Compression) (*Reader, error) {
 	f, err := os.Open(name)
 	if err != nil {
 		return nil, errsys.Wrap(err)
@@ -36,11 +39,27 @@ func NewReader(name string) (*Reader, error) {
 		_ = f.Close()
 		return nil, errsys.Newf("%s is a directory", name)
 	}
-	return &Reader{
+	r := &Reader{
 		name:   name,
 		f:      f,
 		Reader: bufio.NewReader(f),
-	}, nil
+	}
+	if c == Detect {
+		if c = ForName(name); c == None {
+			c = detect(r.Reader)
+		}
+	}
+	if c == None {
+		return r, nil
+	}
+
+	r.z, err = decompress(r.Reader, c)
+	if err != nil {
+		_ = f.Close()
+		return nil, errsys.WrapWith(name+":", err)
+	}
+	r.Reader = bufio.NewReader(r.z)
+	return r, nil
 }
 
 //Name returns the file being read.
@@ -59,7 +78,12 @@ func (f *Reader) Unwrap() *bufio.Reader {
 //
 //It is the callers responsibility to make sure the file is at the
 //logical "end" where reading may continue and then calling reset.
+//
+//If the file is compressed, device.ErrNotFile is returned.
 func (f *Reader) File() (fh *os.File, reset func(), err error) {
+	if f.z != nil {
+		return nil, nil, device.ErrNotFile
+	}
 	reset = func() {
 		f.Reader.Reset(f.f)
 	}
@@ -68,9 +92,15 @@ func (f *Reader) File() (fh *os.File, reset func(), err error) {
 
 //Close f.
 func (f *Reader) Close() error {
-	err := errsys.Wrap(f.f.Close())
+	var err error
+	if f.z != nil {
+		err = errsys.Wrap(f.z.Close())
+	}
+	if cerr := errsys.Wrap(f.f.Close()); err == nil {
+		err = cerr
+	}
 	f.name = "<BROKEN FILE HANDLE>"
-	f.f = nil
+	f.f, f.z = nil, nil
 	f.Reader.Reset(nil)
 	f.Reader = nil
 	return err
@@ -82,23 +112,45 @@ func (f *Reader) Close() error {
 type Writer struct {
 	name      string
 	cancelled bool
-	f         *os.File //the tmp file
+	f         *os.File       //the tmp file
+	z         io.WriteCloser //compresses to f, if compressed
 	*bufio.Writer
 }
 
 var _ device.Writer = (*Writer)(nil)
 
-//NewWriter creates a temporary file to write to and replaces name on Close.
-func NewWriter(name string) (*Writer, error) {
+//NewWriter creates a temporary file to write to, compressed with c,
+//and replaces name on Close.
+//
+//If c is Detect, the compression is chosen by the extension of name.
+func NewWriter(name string, c Compression) (*Writer, error) {
+	if c == Detect {
+		c = ForName(name)
+	}
+	if c == Bzip2 {
+		return nil, errsys.WrapWith(name+":", errBzip2Write)
+	}
 	f, err := ioutil.TempFile(filepath.Split(name))
 	if err != nil {
 		return nil, errsys.Wrap(err)
 	}
-	return &Writer{
-		name:   name,
-		f:      f,
-		Writer: bufio.NewWriter(f),
-	}, nil
+	w := &Writer{
+		name: name,
+		f:    f,
+	}
+	if c == None {
+		w.Writer = bufio.NewWriter(f)
+		return w, nil
+	}
+
+	w.z, err = compress(f, c)
+	if err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return nil, errsys.WrapWith(name+":", err)
+	}
+	w.Writer = bufio.NewWriter(w.z)
+	return w, nil
 }
 
 //Name reports the name the file will have when closed.
@@ -121,7 +173,12 @@ func (f *Writer) Unwrap() *bufio.Writer {
 //
 //It is the callers responsibility to make sure the file is at the
 //logical "end" where writing may continue and then calling reset.
+//
+//If the file is compressed, device.ErrNotFile is returned.
 func (f *Writer) File() (fh *os.File, reset func(), err error) {
+	if f.z != nil {
+		return nil, nil, device.ErrNotFile
+	}
 	if err := f.Writer.Flush(); err != nil {
 		return nil, nil, err
 	}
@@ -138,7 +195,7 @@ func (f *Writer) Close() error {
 	defer func() {
 		f.Writer.Reset(nil)
 		f.Writer = nil
-		f.f = nil
+		f.f, f.z = nil, nil
 		f.name = "<BROKEN FILE HANDLE>"
 	}()
 
@@ -154,9 +211,21 @@ func (f *Writer) Close() error {
 	//if we've been cancelled we don't want to overwrite the file,
 	//just remove the temp file.
 	if f.cancelled {
+		if f.z != nil {
+			_ = f.z.Close()
+		}
+		_ = f.f.Close()
 		return os.Remove(tmpnm)
 	}
 
+	//write out anything the compressor is holding on to
+	if f.z != nil {
+		if err := f.z.Close(); err != nil {
+			_ = f.f.Close()
+			return errsys.Wrap(err)
+		}
+	}
+
 	//sync to the disk, even though is probably a lie
 	if err := f.f.Sync(); err != nil {
 		_ = f.f.Close()
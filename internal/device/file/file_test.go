@@ -0,0 +1,167 @@
+package file
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const contents = "a,b\n1,2\n"
+
+func tmpdir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "etlite-file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func write(t *testing.T, name string, c Compression) {
+	w, err := NewWriter(name, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.WriteString(contents); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func read(t *testing.T, name string, c Compression) string {
+	r, err := NewReader(name, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return string(p)
+}
+
+func TestCompression(t *testing.T) {
+	dir, cleanup := tmpdir(t)
+	defer cleanup()
+
+	for _, c := range []Compression{None, Gzip, XZ, Zstd} {
+		//by extension
+		ext := filepath.Join(dir, "ext.csv"+map[Compression]string{Gzip: ".gz", XZ: ".xz", Zstd: ".zst"}[c])
+		write(t, ext, Detect)
+		if got := read(t, ext, Detect); got != contents {
+			t.Fatalf("%s: read %q from %s", c, got, ext)
+		}
+
+		//explicitly, detected by magic on read
+		magic := filepath.Join(dir, "magic.csv")
+		write(t, magic, c)
+		if c != None {
+			if got := read(t, magic, None); got == contents {
+				t.Fatalf("%s: expected %s to be compressed", c, magic)
+			}
+		}
+		if got := read(t, magic, Detect); got != contents {
+			t.Fatalf("%s: read %q from %s", c, got, magic)
+		}
+	}
+}
+
+func TestBzip2(t *testing.T) {
+	dir, cleanup := tmpdir(t)
+	defer cleanup()
+
+	//bzip2 -9 of contents
+	bz := []byte{
+		0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0xbf, 0x87,
+		0x40, 0x7f, 0x00, 0x00, 0x03, 0x59, 0x00, 0x00, 0x10, 0x00, 0x04, 0x30,
+		0x00, 0x30, 0x00, 0x20, 0x00, 0x30, 0xc0, 0x08, 0x69, 0xb2, 0x88, 0x23,
+		0x27, 0x8b, 0xb9, 0x22, 0x9c, 0x28, 0x48, 0x5f, 0xc3, 0xa0, 0x3f, 0x80,
+	}
+	name := filepath.Join(dir, "x.csv")
+	if err := ioutil.WriteFile(name, bz, 0666); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(t, name, Detect); got != contents {
+		t.Fatalf("read %q", got)
+	}
+
+	if _, err := NewWriter(filepath.Join(dir, "x.csv.bz2"), Detect); err == nil {
+		t.Fatal("expected error writing bzip2")
+	}
+}
+
+func TestCancel(t *testing.T) {
+	dir, cleanup := tmpdir(t)
+	defer cleanup()
+
+	w, err := NewWriter(filepath.Join(dir, "x.csv.gz"), Detect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Cancel()
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	fs, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fs) != 0 {
+		t.Fatalf("expected no files after cancel, got %d", len(fs))
+	}
+}
+
+func TestTrimExt(t *testing.T) {
+	for in, exp := range map[string]string{
+		"orders.csv.gz":  "orders.csv",
+		"orders.CSV.ZST": "orders.CSV",
+		"orders.csv":     "orders.csv",
+		"orders":         "orders",
+		"a.b.xz":         "a.b",
+	} {
+		if got := TrimExt(in); got != exp {
+			t.Errorf("TrimExt(%q): expected %q got %q", in, exp, got)
+		}
+	}
+}
+
+func TestGlob(t *testing.T) {
+	dir, cleanup := tmpdir(t)
+	defer cleanup()
+
+	for _, nm := range []string{"b.csv", "a.csv", "c.txt", ".hidden.csv"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, nm), nil, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "d.csv"), 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	for pattern, exp := range map[string][]string{
+		filepath.Join(dir, "*.csv"): {"a.csv", "b.csv"},
+		dir:                         {"a.csv", "b.csv", "c.txt"},
+	} {
+		got, err := Glob(pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(exp) {
+			t.Fatalf("%s: expected %q got %q", pattern, exp, got)
+		}
+		for i := range exp {
+			if filepath.Base(got[i]) != exp[i] {
+				t.Fatalf("%s: expected %q got %q", pattern, exp, got)
+			}
+		}
+	}
+
+	if _, err := Glob(filepath.Join(dir, "*.json")); err == nil {
+		t.Fatal("expected error when no files match")
+	}
+}
@@ -0,0 +1,51 @@
+package file
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jimmyfrasche/etlite/internal/internal/errsys"
+)
+
+//Glob returns the files matching pattern in sorted order.
+//
+//As in the shell, hidden files are only matched by a pattern
+//that starts with a dot.
+//If pattern is a directory, the files in that directory are returned,
+//excluding subdirectories and hidden files.
+//
+//It is an error if no files match.
+func Glob(pattern string) ([]string, error) {
+	var names []string
+	if fs, err := ioutil.ReadDir(pattern); err == nil {
+		for _, f := range fs {
+			if f.Mode().IsRegular() && !strings.HasPrefix(f.Name(), ".") {
+				names = append(names, filepath.Join(pattern, f.Name()))
+			}
+		}
+	} else {
+		ms, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, errsys.WrapWith(pattern+":", err)
+		}
+		hidden := strings.HasPrefix(filepath.Base(pattern), ".")
+		for _, m := range ms {
+			if hidden == strings.HasPrefix(filepath.Base(m), ".") && isFile(m) {
+				names = append(names, m)
+			}
+		}
+	}
+	if len(names) == 0 {
+		return nil, errsys.Newf("no files match %s", pattern)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func isFile(name string) bool {
+	s, err := os.Stat(name)
+	return err == nil && s.Mode().IsRegular()
+}
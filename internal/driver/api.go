@@ -1,7 +1,11 @@
 //Package driver is a limited, specialized binding to a customized SQLite.
 package driver
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/jimmyfrasche/etlite/internal/internal/value"
+)
 
 var (
 	//NotImplemented is returned when this package is
@@ -48,6 +52,15 @@ func (c *Conn) Close() error {
 	return c.close()
 }
 
+//Interrupt causes any running query to stop
+//at the earliest opportunity with an error.
+//
+//It may be called from another goroutine,
+//but not concurrently with Close.
+func (c *Conn) Interrupt() {
+	c.interrupt()
+}
+
 //Assert a subquery, ensuring it returns a bool.
 func (c *Conn) Assert(query string) (bool, error) {
 	return c.assert(query)
@@ -119,17 +132,45 @@ type BulkLoader struct {
 
 //Load queues a row for loading and loads many rows in bulk
 //when an internal limit is hit
-func (b *BulkLoader) Load(vs []*string) error {
+//
+//Each value is bound with its storage class,
+//except that an INTEGER or REAL whose text is not a number
+//is bound as TEXT.
+//
+//If a row cannot be loaded because of its values,
+//such as violating a constraint, the error is a *RowError.
+//The rows queued after it remain queued.
+func (b *BulkLoader) Load(vs []value.Value) error {
 	return b.load(vs)
 }
 
 //Close flushes any remaining rows.
 //It does not Close the underlying prepared statement.
+//
+//If it returns a *RowError, it must be called again
+//to flush the rows queued after the failed row.
 func (b *BulkLoader) Close() error {
 	//TODO(jmf) rename Flush
 	return b.close()
 }
 
+//Pending reports how many rows are queued but not yet loaded.
+func (b *BulkLoader) Pending() int {
+	return b.pending()
+}
+
+//RowError is returned by a BulkLoader when a row cannot be loaded
+//because of its values.
+type RowError struct {
+	Row    int           //index of the row among all the rows passed to Load, from 0
+	Values []value.Value //of the row
+	Err    error
+}
+
+func (r *RowError) Error() string {
+	return r.Err.Error()
+}
+
 //Iter returns an iterator over the results of the query.
 func (s *Stmt) Iter() (*Iter, error) {
 	i, err := s.iter()
@@ -150,7 +191,8 @@ func (i *Iter) Next() bool {
 }
 
 //Row returns a copy of the current row.
-func (i *Iter) Row() []*string {
+//Each value retains the storage class reported by SQLite.
+func (i *Iter) Row() []value.Value {
 	return i.row()
 }
 
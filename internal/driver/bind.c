@@ -1,4 +1,5 @@
 #include <stdlib.h>
+#include <string.h>
 #include <assert.h>
 
 #include "sqlite3.h"
@@ -72,26 +73,42 @@ error:
  * sqlbind_subquery is the backend of the simulation of a subquery.
  * It assumes none of its arguments are nil and returns the result
  * into s and len.
+ *
+ * If there is a result and it is not NULL, *s is a copy of it,
+ * which the caller must free with stdlib free.
+ * Otherwise, *s is NULL.
  */
 int sqlbind_subquery(sqlite3_stmt *p, char **s, int *len) {
 	int rv = SQLITE_ERROR;
 	if(p == NULL || s == NULL || len == NULL) {
 		return rv;
 	}
+	*s = NULL;
+	*len = 0;
 
 	rv = sqlite3_step(p);
-	if(rv == SQLITE_DONE) {
+	if(rv != SQLITE_ROW) {
+		sqlite3_reset(p);
 		return rv;
 	}
 
-	*len = sqlite3_column_bytes(p, 0);
-	*s = (char *) sqlite3_column_text(p, 0);
+	/* the text is owned by sqlite and freed by the reset */
+	const unsigned char *txt = sqlite3_column_text(p, 0);
+	if(txt != NULL) {
+		*len = sqlite3_column_bytes(p, 0);
+		*s = malloc(*len + 1);
+		if(*s == NULL) {
+			sqlite3_reset(p);
+			return SQLITE_NOMEM;
+		}
+		memcpy(*s, txt, *len + 1);
+	}
 
 	return sqlite3_reset(p);
 }
 
 /*
- * sqlbind_bulk_insert takes a vector of strings
+ * sqlbind_bulk_insert takes a vector of values
  * and repeatedly binds and executes p appropriately.
  *
  * It assumes that
@@ -100,46 +117,72 @@ int sqlbind_subquery(sqlite3_stmt *p, char **s, int *len) {
  * - nbind divides nvars
  * - nbind, nvars > 0
  * - the binds are sequentially numbered and unnamed
- * - the length of vars is nvars
+ * - the length of types, vars, and lens is nvars
  * - the number of binds is nbinds
- * - the vector vars contains only null-terminated UTF-8 encoded strings or NULL
+ * - types[i] is the storage class to bind vars[i] as
+ * - vars[i] is a null-terminated UTF-8 encoded string
+ *   or, if types[i] is SQLITE_BLOB, lens[i] raw bytes,
+ *   or NULL if types[i] is SQLITE_NULL
+ * - the text of SQLITE_INTEGER and SQLITE_FLOAT values is a valid number
  * - each string can be safely freed after the call by stdlib free
  * - it is *not* responsible for savepointing or transactions
  *
  * It assures that
  * - the first error found aborts the process and is returned
+ * - *inserted is the number of rows inserted before the first error
+ * - p is reset and has no variables bound, even after an error
  * - each string is freed
- * - the vector itself is freed
+ * - the vectors themselves are freed
  * - resetting and rebinding of p is complete during each run
- * - C NULL entries in vars are assigned to SQL NULL
  */
-int sqlbind_bulk_insert(sqlite3_stmt *p, int nbind, char **vars, int nvars) {
+int sqlbind_bulk_insert(sqlite3_stmt *p, int nbind, int *types, char **vars, int *lens, int nvars, int *inserted) {
 	assert(p != NULL);
+	assert(types != NULL);
 	assert(vars != NULL);
+	assert(lens != NULL);
+	assert(inserted != NULL);
 	assert(nbind > 0);
 	assert(nvars > 0);
 	assert(nvars%nbind == 0);
 	int rv = SQLITE_ERROR;
 	int pos = 0;
 
+	*inserted = 0;
 	if(nvars == 0) {
 		return rv;
 	}
 
-	/* Potential performance improvement: use pascal style strings
-	 * so sqlite needn't strlen each arg, but hard to work around
-	 * how cgo marshals Go strings to C.
-	 * Likely more trouble than it's worth. */
-
 	for(int step = 0; step < nvars/nbind; step++) {
 		for(int n = 0; n < nbind; n++) {
-			rv = sqlite3_bind_text(p, n+1, vars[pos], -1, &free); /* NB sqlite3_bind_text
-																	given a NULL is the same as sqlite3_bind_null */
+			switch(types[pos]) {
+			case SQLITE_NULL:
+				rv = sqlite3_bind_null(p, n+1);
+				free(vars[pos]);
+				break;
+			case SQLITE_INTEGER:
+				rv = sqlite3_bind_int64(p, n+1, strtoll(vars[pos], NULL, 10));
+				free(vars[pos]);
+				break;
+			case SQLITE_FLOAT:
+				rv = sqlite3_bind_double(p, n+1, strtod(vars[pos], NULL));
+				free(vars[pos]);
+				break;
+			case SQLITE_BLOB:
+				if(lens[pos] == 0) {
+					/* binding a NULL pointer would bind NULL */
+					rv = sqlite3_bind_zeroblob(p, n+1, 0);
+					free(vars[pos]);
+				} else {
+					rv = sqlite3_bind_blob(p, n+1, vars[pos], lens[pos], &free);
+				}
+				break;
+			default:
+				rv = sqlite3_bind_text(p, n+1, vars[pos], lens[pos], &free);
+			}
+			pos++; /* sqlite frees the string even if the bind fails */
 			if(rv != SQLITE_OK) {
 				goto error;
 			}
-			pos++; /* need to keep track of consumed arguments in case of error */
-
 		}
 		rv = sqlite3_step(p);
 		if(rv != SQLITE_DONE) {
@@ -155,38 +198,65 @@ int sqlbind_bulk_insert(sqlite3_stmt *p, int nbind, char **vars, int nvars) {
 		if(rv != SQLITE_OK) {
 			goto error;
 		}
+		(*inserted)++;
 	}
 	goto done;
 
 error:
+	/* leave p ready for the rows after the failed row.
+	 * Resetting returns the error again and keeps its message. */
+	sqlite3_reset(p);
+	sqlite3_clear_bindings(p);
+
 	/* need to clean up unused inputs */
 	for(; pos < nvars; pos++) {
-		if(vars+pos != NULL) {
-			free(vars+pos);
-		}
+		free(vars[pos]);
 	}
 
 done:
+	free(types);
 	free(vars);
+	free(lens);
 	return rv;
 }
 
 /*
  * sqlbind_bulk_read reads a statement a row at a time.
- * It assumes p is valid and has columns to query.
- * */
-int sqlbind_bulk_read(sqlite3_stmt *p, char ***vars, int *ncols) {
+ *
+ * It assumes that
+ * - p is valid and has ncols columns to query
+ * - types, vals, and lens each have room for ncols entries
+ *
+ * It assures that, for each column i,
+ * - types[i] is the storage class of the value, as given by sqlite3_column_type
+ * - vals[i] is NULL if the value is NULL,
+ *   the raw bytes if the value is a BLOB,
+ *   and the value rendered as text otherwise
+ * - lens[i] is the length of vals[i] in bytes
+ * - the entries in vals are owned by sqlite and only valid until p is next
+ *   stepped, reset, or finalized
+ */
+int sqlbind_bulk_read(sqlite3_stmt *p, int ncols, int *types, const char **vals, int *lens) {
+	assert(ncols > 0);
 	int rv = sqlite3_step(p);
 	if(rv == SQLITE_DONE || rv != SQLITE_ROW) {
 		return rv;
 	}
 
-	*ncols = sqlite3_column_count(p);
-	assert(*ncols > 0);
-
-	*vars = (char**) malloc(*ncols * sizeof(char**));
-	for(int i = 0; i < *ncols; i++) {
-		(*vars)[i] = (char*) sqlite3_column_text(p, i);
+	for(int i = 0; i < ncols; i++) {
+		/* the type must be read before any conversion */
+		types[i] = sqlite3_column_type(p, i);
+		switch(types[i]) {
+		case SQLITE_NULL:
+			vals[i] = NULL;
+			break;
+		case SQLITE_BLOB:
+			vals[i] = (const char *) sqlite3_column_blob(p, i);
+			break;
+		default:
+			vals[i] = (const char *) sqlite3_column_text(p, i);
+		}
+		lens[i] = sqlite3_column_bytes(p, i);
 	}
 
 	return rv;
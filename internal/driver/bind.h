@@ -15,7 +15,7 @@
 
 int sqlbind_assert_query(sqlite3 *, char *, int, int *);
 int sqlbind_subquery(sqlite3_stmt *, char **, int *);
-int sqlbind_bulk_insert(sqlite3_stmt *, int, char **, int);
-int sqlbind_bulk_read(sqlite3_stmt *, char ***, int *);
+int sqlbind_bulk_insert(sqlite3_stmt *, int, int *, char **, int *, int, int *);
+int sqlbind_bulk_read(sqlite3_stmt *, int, int *, const char **, int *);
 
 #endif
@@ -37,9 +37,11 @@ import "C"
 
 import (
 	"errors"
+	"strconv"
 	"unsafe"
 
 	"github.com/jimmyfrasche/etlite/internal/internal/errint"
+	"github.com/jimmyfrasche/etlite/internal/internal/value"
 )
 
 //bulkRowsAtOnce is how many rows we strive to handle at a time
@@ -105,6 +107,13 @@ func (c *conn) close() error {
 	return err
 }
 
+func (c *conn) interrupt() {
+	if c == nil || c.db == nil {
+		return
+	}
+	C.sqlite3_interrupt(c.db)
+}
+
 func (c *conn) assert(query string) (bool, error) {
 	if c == nil || c.db == nil {
 		return false, errint.New("no database connection when asserting")
@@ -139,7 +148,7 @@ func (c *conn) assert(query string) (bool, error) {
 		}
 	}
 
-	return out != 1, nil
+	return out == 1, nil
 }
 
 func (c *conn) prepare(query string) (*stmt, error) {
@@ -235,9 +244,13 @@ func (s *stmt) subquery() (*string, error) {
 		return nil, errors.New("a subquery can only return a single result")
 	}
 
+	//cstr is a copy made by sqlbind_subquery that we must free
 	var cstr *C.char
 	var length C.int
 	rv := C.sqlbind_subquery(s.p, &cstr, &length)
+	if cstr != nil {
+		defer C.free(unsafe.Pointer(cstr))
+	}
 	if !ok(rv) {
 		return nil, errmsg(s.c.db)
 	}
@@ -247,7 +260,6 @@ func (s *stmt) subquery() (*string, error) {
 	}
 
 	result := C.GoStringN(cstr, length)
-	C.free(unsafe.Pointer(cstr))
 	return &result, nil
 }
 
@@ -255,7 +267,10 @@ func (s *stmt) subquery() (*string, error) {
 //The new API is clumsily and hastily implemented in terms of it.
 //It needs to be rewritten into the new API at some point.
 
-func (s *stmt) bulkLoad(xs []*string) error {
+//bulkLoad loads the rows in xs.
+//If a row fails because of its values, the error is a *RowError
+//whose Row is the index of the row in xs.
+func (s *stmt) bulkLoad(xs []value.Value) error {
 	if s.binds == 0 {
 		return errors.New("attempting to bulk load on statement without bound variables")
 	}
@@ -263,27 +278,67 @@ func (s *stmt) bulkLoad(xs []*string) error {
 		return nil
 	}
 
-	//convert xs into char**
+	//convert xs into parallel vectors of storage classes, char*, and lengths
 	L := C.int(len(xs))
+	intsz := C.size_t(unsafe.Sizeof(C.int(0)))
 	ptrsz := C.size_t(unsafe.Sizeof((*C.char)(nil)))
+	types := (*C.int)(C.malloc(intsz * C.size_t(L)))
 	arr := (**C.char)(C.malloc(ptrsz * C.size_t(L)))
+	lens := (*C.int)(C.malloc(intsz * C.size_t(L)))
+	tview := (*[1 << 30]C.int)(unsafe.Pointer(types))[:len(xs):len(xs)]
 	view := (*[1 << 30]*C.char)(unsafe.Pointer(arr))[:len(xs):len(xs)]
+	lview := (*[1 << 30]C.int)(unsafe.Pointer(lens))[:len(xs):len(xs)]
 	for i, x := range xs {
-		if x == nil {
+		tview[i] = bindType(x)
+		lview[i] = C.int(len(x.Text))
+		switch x.Kind {
+		case value.Null:
 			view[i] = nil
-		} else {
-			view[i] = C.CString(*x)
+		case value.Blob:
+			view[i] = (*C.char)(C.CBytes([]byte(x.Text)))
+		default:
+			view[i] = C.CString(x.Text)
 		}
 	}
 
-	rv := C.sqlbind_bulk_insert(s.p, s.binds, arr, L)
+	var inserted C.int
+	rv := C.sqlbind_bulk_insert(s.p, s.binds, types, arr, lens, L, &inserted)
 	if !ok(rv) {
-		return errmsg(s.c.db)
+		err := errmsg(s.c.db)
+		switch rv & 0xff {
+		case C.SQLITE_CONSTRAINT, C.SQLITE_MISMATCH, C.SQLITE_TOOBIG:
+			return &RowError{
+				Row: int(inserted),
+				Err: err,
+			}
+		}
+		return err
 	}
 
 	return nil
 }
 
+//bindType returns the storage class to bind v as.
+//Numbers that SQLite cannot parse are bound as text,
+//leaving them to the affinity of the column.
+func bindType(v value.Value) C.int {
+	switch v.Kind {
+	case value.Null:
+		return C.SQLITE_NULL
+	case value.Integer:
+		if _, err := strconv.ParseInt(v.Text, 10, 64); err == nil {
+			return C.SQLITE_INTEGER
+		}
+	case value.Real:
+		if _, err := strconv.ParseFloat(v.Text, 64); err == nil {
+			return C.SQLITE_FLOAT
+		}
+	case value.Blob:
+		return C.SQLITE_BLOB
+	}
+	return C.SQLITE_TEXT
+}
+
 func (s *stmt) loader() (*bulkLoader, error) {
 	binds := int(s.binds)
 	if binds == 0 {
@@ -291,17 +346,18 @@ func (s *stmt) loader() (*bulkLoader, error) {
 	}
 	return &bulkLoader{
 		s:   s,
-		acc: make([]*string, 0, bulkRowsAtOnce*binds),
+		acc: make([]value.Value, 0, bulkRowsAtOnce*binds),
 	}, nil
 }
 
 type bulkLoader struct {
-	s *stmt
-	n int //how many rows we've seen this pass
+	s      *stmt
+	n      int //how many rows we've seen this pass
+	loaded int //how many rows were loaded, or failed, in previous passes
 
 	//TODO refactor sqlbind_bulk_insert and replace this with
 	//an allocated once C vector
-	acc []*string
+	acc []value.Value
 }
 
 func (b *bulkLoader) flush() error {
@@ -310,11 +366,26 @@ func (b *bulkLoader) flush() error {
 	}
 	//TODO replace with basically what's in b.s.bulkLoad but reusing the C vector
 	err := b.s.bulkLoad(b.acc)
-	b.acc = b.acc[:0]
-	return err
+	rerr, ok := err.(*RowError)
+	if !ok {
+		b.loaded += b.n
+		b.n = 0
+		b.acc = b.acc[:0]
+		return err
+	}
+
+	//report the failed row and keep the rows after it for the next pass
+	binds := int(b.s.binds)
+	failed := rerr.Row + 1
+	rerr.Values = append([]value.Value(nil), b.acc[rerr.Row*binds:failed*binds]...)
+	rerr.Row += b.loaded
+	b.acc = b.acc[:copy(b.acc, b.acc[failed*binds:])]
+	b.loaded += failed
+	b.n -= failed
+	return rerr
 }
 
-func (b *bulkLoader) load(vs []*string) error {
+func (b *bulkLoader) load(vs []value.Value) error {
 	//TODO replace with "append" to C vec
 	b.acc = append(b.acc, vs...)
 
@@ -332,11 +403,19 @@ func (b *bulkLoader) close() error {
 	}
 
 	err := b.flush()
+	if _, ok := err.(*RowError); ok {
+		//may be called again to load the remaining rows
+		return err
+	}
 	b.s = nil
 	b.acc = nil //TODO replace by freeing C vector allocated in loader
 	return err
 }
 
+func (b *bulkLoader) pending() int {
+	return b.n
+}
+
 func (s *stmt) iter() (*iter, error) {
 	if s == nil || s.p == nil {
 		return nil, errors.New("cannot create iterator for malformed statement")
@@ -346,7 +425,18 @@ func (s *stmt) iter() (*iter, error) {
 		return nil, errors.New("cannot iterate over stored procedure with binds")
 	}
 
-	return &iter{c: s.c, s: s}, nil
+	n := len(s.cols)
+	if n == 0 {
+		return nil, errors.New("cannot iterate over statement with no columns")
+	}
+
+	return &iter{
+		c:     s.c,
+		s:     s,
+		types: make([]C.int, n),
+		vals:  make([]*C.char, n),
+		lens:  make([]C.int, n),
+	}, nil
 }
 
 type iter struct {
@@ -354,16 +444,30 @@ type iter struct {
 	s    *stmt
 	err  error
 	done bool
-	dat  []*string
+	dat  []value.Value
+
+	//scratch space filled in by sqlbind_bulk_read.
+	//vals only holds pointers owned by sqlite.
+	types []C.int
+	vals  []*C.char
+	lens  []C.int
+}
+
+//kinds maps the sqlite fundamental datatypes to storage classes.
+var kinds = map[C.int]value.Kind{
+	C.SQLITE_NULL:    value.Null,
+	C.SQLITE_INTEGER: value.Integer,
+	C.SQLITE_FLOAT:   value.Real,
+	C.SQLITE_TEXT:    value.Text,
+	C.SQLITE_BLOB:    value.Blob,
 }
 
 func (i *iter) next() bool {
 	if i == nil || i.err != nil || i.c == nil || i.s == nil || i.done {
 		return false
 	}
-	var n C.int
-	var ret **C.char
-	rv := C.sqlbind_bulk_read(i.s.p, &ret, &n)
+	n := len(i.vals)
+	rv := C.sqlbind_bulk_read(i.s.p, C.int(n), &i.types[0], &i.vals[0], &i.lens[0])
 	if !ok(rv) {
 		i.done = true
 		i.err = errmsg(i.c.db)
@@ -373,20 +477,23 @@ func (i *iter) next() bool {
 		i.done = true
 		return false
 	}
-	defer C.free(unsafe.Pointer(ret))
-	N := int(n)
-	view := (*[1 << 30]*C.char)(unsafe.Pointer(ret))[:N:N]
-	i.dat = make([]*string, N)
-	for n, cs := range view {
+	i.dat = make([]value.Value, n)
+	for j, cs := range i.vals {
+		kind, ok := kinds[i.types[j]]
+		if !ok {
+			i.done = true
+			i.err = errint.Newf("unknown sqlite datatype %d", i.types[j])
+			return false
+		}
+		i.dat[j].Kind = kind
 		if cs != nil {
-			s := C.GoString(cs)
-			i.dat[n] = &s
+			i.dat[j].Text = C.GoStringN(cs, i.lens[j])
 		}
 	}
 	return true
 }
 
-func (i *iter) row() []*string {
+func (i *iter) row() []value.Value {
 	if i == nil || i.err != nil || i.c == nil || i.s == nil || i.done {
 		return nil
 	}
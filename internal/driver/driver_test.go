@@ -9,6 +9,8 @@ import (
 	"os"
 	"strconv"
 	"testing"
+
+	"github.com/jimmyfrasche/etlite/internal/internal/value"
 )
 
 func TestMain(m *testing.M) {
@@ -83,28 +85,34 @@ func TestPrepare(t *testing.T) {
 	})
 }
 
-func s(r string) *string {
-	return &r
+func integer(s string) value.Value {
+	return value.Value{Kind: value.Integer, Text: s}
 }
 
-var table = [][]*string{
-	{s("1"), nil, s("squirrel")},
-	{s("2"), s("two"), nil},
-	{s("3"), nil, nil},
-	{s("4"), s("avocado"), s("σ∈⁵ℝ«⌋")},
+func s(r string) value.Value {
+	return value.String(r)
 }
 
-func fmts(s *string) string {
-	if s == nil {
+var null value.Value
+
+var table = [][]value.Value{
+	{integer("1"), null, s("squirrel")},
+	{integer("2"), s("two"), null},
+	{integer("3"), null, null},
+	{integer("4"), s("avocado"), s("σ∈⁵ℝ«⌋")},
+}
+
+func fmtv(v value.Value) string {
+	if v.IsNull() {
 		return "<nil>"
 	}
-	return strconv.Quote(*s)
+	return v.Kind.String() + ":" + strconv.Quote(v.Text)
 }
 
-func fmtvec(xs []*string) string {
+func fmtvec(xs []value.Value) string {
 	s := "["
 	for i, x := range xs {
-		s += fmts(x)
+		s += fmtv(x)
 		if i != len(xs)-1 {
 			s += ", "
 		}
@@ -113,18 +121,14 @@ func fmtvec(xs []*string) string {
 	return s
 }
 
-func cmp(xs, ys []*string) error {
+func cmp(xs, ys []value.Value) error {
 	if len(xs) != len(ys) {
 		return fmt.Errorf("|xs|=%d but |ys|=%d", len(xs), len(ys))
 	}
 
 	for i, x := range xs {
-		y := ys[i]
-		if x == nil && y == nil {
-			continue
-		}
-		if x == nil && y != nil || y == nil && x != nil || *x != *y {
-			return fmt.Errorf("expected: %s but got: %s", fmts(x), fmts(y))
+		if y := ys[i]; x != y {
+			return fmt.Errorf("expected: %s but got: %s", fmtv(x), fmtv(y))
 		}
 	}
 
@@ -151,7 +155,7 @@ func TestBind(t *testing.T) {
 			}
 		}
 
-		create := open("CREATE TABLE t (a INT, b TEXT, c TEXT)") //TODO test with c BLOB
+		create := open("CREATE TABLE t (a INT, b TEXT, c TEXT)")
 		defer cleanup("create", create)
 		if err := create.Exec(); err != nil {
 			t.Fatal("could not exec create table, got:", err)
@@ -182,9 +186,10 @@ func TestBind(t *testing.T) {
 		}
 		i := 0
 		for iter.Next() {
-			if err := cmp(table[i], iter.Row()); err != nil {
+			exp := table[i]
+			if err := cmp(exp, iter.Row()); err != nil {
 				t.Log("Reading row", i)
-				t.Log("Expected:", fmtvec(table[i]))
+				t.Log("Expected:", fmtvec(exp))
 				t.Log("Got:", fmtvec(iter.Row()))
 				t.Fatal(err)
 			}
@@ -199,10 +204,315 @@ func TestBind(t *testing.T) {
 	})
 }
 
+//TestLoaderRowError tests that a row violating a constraint is reported
+//and that the rows queued after it are still loaded.
+func TestLoaderRowError(t *testing.T) {
+	with(t, func(c *Conn) {
+		create, err := c.Prepare("CREATE TABLE t (a INTEGER PRIMARY KEY)")
+		if err != nil {
+			t.Fatal("could not prepare create table, got:", err)
+		}
+		defer create.Close()
+		if err := create.Exec(); err != nil {
+			t.Fatal("could not exec create table, got:", err)
+		}
+
+		load, err := c.Prepare("INSERT INTO t VALUES (?)")
+		if err != nil {
+			t.Fatal("could not prepare insert, got:", err)
+		}
+		defer load.Close()
+		loader, err := load.Loader()
+		if err != nil {
+			t.Fatal("could not create loader, got:", err)
+		}
+
+		//enough rows for more than one bulk insert, with duplicates in each
+		n := 2*bulkRowsAtOnce + 3
+		dups := map[int]bool{3: true, bulkRowsAtOnce + 1: true, n - 1: true}
+		var failed []int
+		check := func(err error) {
+			if err == nil {
+				return
+			}
+			rerr, ok := err.(*RowError)
+			if !ok {
+				t.Fatal("expected a RowError, got:", err)
+			}
+			if len(rerr.Values) != 1 || rerr.Values[0] != integer("0") {
+				t.Fatalf("row %d: expected the values of the failed row, got: %v", rerr.Row, rerr.Values)
+			}
+			failed = append(failed, rerr.Row)
+		}
+		for i := 0; i < n; i++ {
+			v := strconv.Itoa(i)
+			if dups[i] {
+				v = "0"
+			}
+			check(loader.Load([]value.Value{integer(v)}))
+		}
+		for {
+			err := loader.Close()
+			if _, ok := err.(*RowError); !ok {
+				if err != nil {
+					t.Fatal("could not close loader, got:", err)
+				}
+				break
+			}
+			check(err)
+		}
+
+		if len(failed) != len(dups) {
+			t.Fatalf("expected rows %v to fail, got: %v", dups, failed)
+		}
+		for _, row := range failed {
+			if !dups[row] {
+				t.Fatalf("expected rows %v to fail, got: %v", dups, failed)
+			}
+		}
+
+		count, err := c.Prepare("SELECT count(*) FROM t")
+		if err != nil {
+			t.Fatal("could not prepare count, got:", err)
+		}
+		defer count.Close()
+		iter, err := count.Iter()
+		if err != nil {
+			t.Fatal("could not create iterator, got:", err)
+		}
+		if !iter.Next() {
+			t.Fatal("could not count rows, got:", iter.Err())
+		}
+		if exp := strconv.Itoa(n - len(dups)); iter.Row()[0].Text != exp {
+			t.Fatalf("expected %s rows, got: %s", exp, iter.Row()[0].Text)
+		}
+	})
+}
+
+//TestTypes tests that iter reports the storage class of each value
+//and does not mangle blobs.
+func TestTypes(t *testing.T) {
+	with(t, func(c *Conn) {
+		s, err := c.Prepare("SELECT NULL, 1, 1.5, 'x', x'00ff00'")
+		if err != nil {
+			t.Fatal("could not prepare statement:", err)
+		}
+		defer s.Close()
+
+		iter, err := s.Iter()
+		if err != nil {
+			t.Fatal("could not create iterator, got:", err)
+		}
+		if !iter.Next() {
+			t.Fatal("expected a row, got:", iter.Err())
+		}
+		exp := []value.Value{
+			{},
+			{Kind: value.Integer, Text: "1"},
+			{Kind: value.Real, Text: "1.5"},
+			{Kind: value.Text, Text: "x"},
+			{Kind: value.Blob, Text: "\x00\xff\x00"},
+		}
+		if err := cmp(exp, iter.Row()); err != nil {
+			t.Log("Expected:", fmtvec(exp))
+			t.Log("Got:", fmtvec(iter.Row()))
+			t.Fatal(err)
+		}
+		if iter.Next() {
+			t.Fatal("expected one row")
+		}
+		if err := iter.Err(); err != nil {
+			t.Fatal("iterator reported:", err)
+		}
+	})
+}
+
+//TestLoadTypes tests that the loader binds each value with its storage class.
+func TestLoadTypes(t *testing.T) {
+	with(t, func(c *Conn) {
+		create, err := c.Prepare("CREATE TABLE t (a)")
+		if err != nil {
+			t.Fatal("could not prepare create table, got:", err)
+		}
+		defer create.Close()
+		if err := create.Exec(); err != nil {
+			t.Fatal("could not exec create table, got:", err)
+		}
+
+		load, err := c.Prepare("INSERT INTO t VALUES (?)")
+		if err != nil {
+			t.Fatal("could not prepare insert, got:", err)
+		}
+		defer load.Close()
+		loader, err := load.Loader()
+		if err != nil {
+			t.Fatal("could not create loader, got:", err)
+		}
+		in := []value.Value{
+			null,
+			integer("-12"),
+			{Kind: value.Real, Text: "1.5"},
+			s("1"),
+			{Kind: value.Blob, Text: "\x00\xff\x00"},
+			{Kind: value.Blob},
+			integer("x"),
+		}
+		exp := append([]value.Value(nil), in...)
+		exp[len(exp)-1] = s("x")
+		for _, v := range in {
+			if err := loader.Load([]value.Value{v}); err != nil {
+				t.Fatal("could not load", fmtv(v), "got:", err)
+			}
+		}
+		if err := loader.Close(); err != nil {
+			t.Fatal("could not close loader, got:", err)
+		}
+
+		read, err := c.Prepare("SELECT a FROM t")
+		if err != nil {
+			t.Fatal("could not prepare select, got:", err)
+		}
+		defer read.Close()
+		iter, err := read.Iter()
+		if err != nil {
+			t.Fatal("could not create iterator, got:", err)
+		}
+		var got []value.Value
+		for iter.Next() {
+			got = append(got, iter.Row()[0])
+		}
+		if err := iter.Err(); err != nil {
+			t.Fatal("iterator reported:", err)
+		}
+		if err := cmp(exp, got); err != nil {
+			t.Log("Expected:", fmtvec(exp))
+			t.Log("Got:", fmtvec(got))
+			t.Fatal(err)
+		}
+	})
+}
+
 func TestAssert(t *testing.T) {
-	t.Error("TODO")
+	with(t, func(c *Conn) {
+		for q, exp := range map[string]bool{
+			"SELECT 1":         true,
+			"SELECT 0":         false,
+			"SELECT 2 > 1":     true,
+			"SELECT 'a' = 'b'": false,
+			"SELECT count(*) > 1 FROM (SELECT 1 UNION ALL SELECT 2)": true,
+		} {
+			got, err := c.Assert(q)
+			if err != nil {
+				t.Fatalf("%s: got error: %s", q, err)
+			}
+			if got != exp {
+				t.Fatalf("%s: expected %v got %v", q, exp, got)
+			}
+		}
+
+		for _, q := range []string{
+			"SELECT 2",
+			"SELECT 'x'",
+			"SELECT NULL",
+			"SELECT 1, 1",
+			"SELECT 1 WHERE 0",
+			"SELECT 1 UNION ALL SELECT 1",
+			"SELECT * FROM nosuchtable",
+		} {
+			if got, err := c.Assert(q); err == nil {
+				t.Fatalf("%s: expected error, got %v", q, got)
+			}
+		}
+	})
+}
+
+//TestAssertExists tests the query ASSERT msg, @x compiles to,
+//which must be true only if x is set.
+func TestAssertExists(t *testing.T) {
+	with(t, func(c *Conn) {
+		for _, q := range []string{
+			"CREATE TABLE vars (name TEXT PRIMARY KEY, value)",
+			"INSERT INTO vars VALUES ('x', 'v'), ('empty', '')",
+		} {
+			s, err := c.Prepare(q)
+			if err != nil {
+				t.Fatalf("could not prepare %q, got: %s", q, err)
+			}
+			if err := s.Exec(); err != nil {
+				t.Fatalf("could not exec %q, got: %s", q, err)
+			}
+			if err := s.Close(); err != nil {
+				t.Fatal("could not close statement, got:", err)
+			}
+		}
+
+		for name, exp := range map[string]bool{
+			"x":     true,
+			"empty": true,
+			"y":     false,
+		} {
+			q := "SELECT (SELECT value FROM vars WHERE name='" + name + "') IS NOT NULL"
+			got, err := c.Assert(q)
+			if err != nil {
+				t.Fatalf("%s: got error: %s", q, err)
+			}
+			if got != exp {
+				t.Fatalf("%s: expected %v got %v", q, exp, got)
+			}
+		}
+	})
 }
 
 func TestSubquery(t *testing.T) {
-	t.Error("TODO")
+	with(t, func(c *Conn) {
+		for _, test := range []struct {
+			q   string
+			exp *string
+		}{
+			{"SELECT 'a' || 'b'", sp("ab")},
+			{"SELECT 1 + 1", sp("2")},
+			{"SELECT ''", sp("")},
+			{"SELECT NULL", nil},
+			{"SELECT 1 WHERE 0", nil},
+			{"SELECT x FROM (SELECT 'first' AS x UNION ALL SELECT 'second') ORDER BY x", sp("first")},
+		} {
+			s, err := c.Prepare(test.q)
+			if err != nil {
+				t.Fatalf("could not prepare %q, got: %s", test.q, err)
+			}
+			//the statement must be reset to be run again
+			for i := 0; i < 2; i++ {
+				got, err := s.Subquery()
+				if err != nil {
+					t.Fatalf("%s: got error: %s", test.q, err)
+				}
+				if (got == nil) != (test.exp == nil) || got != nil && *got != *test.exp {
+					t.Fatalf("%s: expected %s got %s", test.q, fmtsp(test.exp), fmtsp(got))
+				}
+			}
+			if err := s.Close(); err != nil {
+				t.Fatal("could not close statement, got:", err)
+			}
+		}
+
+		s, err := c.Prepare("SELECT 1, 2")
+		if err != nil {
+			t.Fatal("could not prepare statement, got:", err)
+		}
+		defer s.Close()
+		if _, err := s.Subquery(); err == nil {
+			t.Fatal("expected a subquery with two columns to fail")
+		}
+	})
+}
+
+func sp(s string) *string {
+	return &s
+}
+
+func fmtsp(s *string) string {
+	if s == nil {
+		return "<nil>"
+	}
+	return strconv.Quote(*s)
 }
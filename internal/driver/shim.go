@@ -2,7 +2,11 @@
 
 package driver
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/jimmyfrasche/etlite/internal/internal/value"
+)
 
 //This file contains a stub implementation of all cgo dependent functions,
 //allowing analysis tools that do not work well with cgo to run.
@@ -22,6 +26,8 @@ func (c *conn) close() error {
 	return nil
 }
 
+func (c *conn) interrupt() {}
+
 func (c *conn) assert(string) (bool, error) {
 	return false, NotImplemented
 }
@@ -59,7 +65,11 @@ func (s *stmt) loader() (*bulkLoader, error) {
 type bulkLoader struct {
 }
 
-func (s *stmt) load(_ []*string) error {
+func (b *bulkLoader) pending() int {
+	return 0
+}
+
+func (s *stmt) load(_ []value.Value) error {
 	return nil
 }
 
@@ -77,7 +87,7 @@ func (i *iter) next() bool {
 	return false
 }
 
-func (i *iter) row() []*string {
+func (i *iter) row() []value.Value {
 	return nil
 }
 
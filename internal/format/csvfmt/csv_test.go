@@ -0,0 +1,147 @@
+package csvfmt
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func readAll(t *testing.T, r *reader) [][]string {
+	var out [][]string
+	for {
+		rec, err := r.read()
+		if err == io.EOF {
+			return out
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, append([]string(nil), rec...))
+	}
+}
+
+var readTests = []struct {
+	name, in                      string
+	comma, quote, escape, comment rune
+	trim                          bool
+	exp                           [][]string
+}{
+	{
+		name: "default",
+		in:   "a,b\r\n\"x,\"\"y\"\"\",\"multi\nline\"\n\n,last",
+		exp:  [][]string{{"a", "b"}, {`x,"y"`, "multi\nline"}, {"", "last"}},
+	},
+	{
+		name:  "single quotes and pipes",
+		in:    "'a|b'|c\n'it''s'|d\n",
+		comma: '|',
+		quote: '\'',
+		exp:   [][]string{{"a|b", "c"}, {"it's", "d"}},
+	},
+	{
+		name:   "escape",
+		in:     `"a\"b","c\\d"` + "\n",
+		escape: '\\',
+		exp:    [][]string{{`a"b`, `c\d`}},
+	},
+	{
+		name:    "comment and trim",
+		in:      "# skip, me\n  a,  \"b\"\n#and me",
+		comment: '#',
+		trim:    true,
+		exp:     [][]string{{"a", "b"}},
+	},
+}
+
+func TestReader(t *testing.T) {
+	for _, test := range readTests {
+		r := newReader(bufio.NewReader(strings.NewReader(test.in)))
+		if test.comma != 0 {
+			r.comma = test.comma
+		}
+		if test.quote != 0 {
+			r.quote = test.quote
+		}
+		r.escape, r.comment, r.trim = test.escape, test.comment, test.trim
+
+		got := readAll(t, r)
+		if len(got) != len(test.exp) {
+			t.Fatalf("%s: expected %q got %q", test.name, test.exp, got)
+		}
+		for i := range got {
+			if e, g := strings.Join(test.exp[i], "\x00"), strings.Join(got[i], "\x00"); e != g {
+				t.Fatalf("%s: record %d expected %q got %q", test.name, i, test.exp[i], got[i])
+			}
+		}
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	for _, in := range []string{
+		"a\n\"unterminated",
+		"a\n\"x\"y",
+	} {
+		r := newReader(bufio.NewReader(strings.NewReader(in)))
+		if _, err := r.read(); err != nil {
+			t.Fatal(err)
+		}
+		_, err := r.read()
+		if err != errQuote && err != errAfterQuote {
+			t.Fatalf("%q: expected format error got %v", in, err)
+		}
+		if r.line != 2 {
+			t.Fatalf("%q: expected error on line 2 got %d", in, r.line)
+		}
+	}
+}
+
+func TestReaderResumes(t *testing.T) {
+	r := newReader(bufio.NewReader(strings.NewReader("a,b\n\"x\"y,z\nc,d\n")))
+	if _, err := r.read(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.read(); err != errAfterQuote {
+		t.Fatalf("expected %v got %v", errAfterQuote, err)
+	}
+	rec, err := r.read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(rec, ",") != "c,d" || r.line != 3 {
+		t.Fatalf("expected c,d on line 3 got %q on line %d", rec, r.line)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	records := [][]string{
+		{"plain", "", " lead", "a|b"},
+		{"'quoted'", `back\slash`, "new\nline", "π"},
+	}
+	for _, escape := range []rune{0, '\\'} {
+		var buf bytes.Buffer
+		bw := bufio.NewWriter(&buf)
+		w := newWriter(bw, '|', '\'', escape, false)
+		for _, rec := range records {
+			if err := w.write(rec); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := bw.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		r := newReader(bufio.NewReader(&buf))
+		r.comma, r.quote, r.escape = '|', '\'', escape
+		got := readAll(t, r)
+		if len(got) != len(records) {
+			t.Fatalf("escape %q: expected %q got %q", escape, records, got)
+		}
+		for i := range got {
+			if e, g := strings.Join(records[i], "\x00"), strings.Join(got[i], "\x00"); e != g {
+				t.Fatalf("escape %q: record %d expected %q got %q", escape, i, records[i], got[i])
+			}
+		}
+	}
+}
@@ -1,7 +1,6 @@
 package csvfmt
 
 import (
-	"encoding/csv"
 	"fmt"
 	"io"
 
@@ -13,29 +12,29 @@ import (
 //Decoder is a CSV decoder.
 type Decoder struct {
 	Null             null.Encoding
-	Comma, Comment   rune
-	Quote            rune //unused
-	TrimLeadingSpace bool //unused
-	UseCRLF          bool //unused
+	Comma            rune
+	Comment          rune //If nonzero, lines starting with Comment are ignored
+	Quote            rune
+	Escape           rune //If nonzero and not Quote, escapes the next rune in a quoted field
+	TrimLeadingSpace bool //Ignore leading white space in a field
 
 	Strict   bool
 	NoHeader bool
 
-	csv *csv.Reader
+	csv *reader
 	acc []*string
 	hdr []string
 
-	nm  string
-	lno int
+	nm string
 
 	resumed bool
 }
 
 func (d *Decoder) ctx() string {
-	return fmt.Sprintf("%s:%d:", d.nm, d.lno)
+	return fmt.Sprintf("%s:%d:", d.nm, d.csv.line)
 }
 
-var _ format.Decoder = (*Decoder)(nil)
+var _ format.LineDecoder = (*Decoder)(nil)
 
 func (*Decoder) Name() string {
 	return "CSV"
@@ -43,17 +42,24 @@ func (*Decoder) Name() string {
 
 func (d *Decoder) Init(in device.Reader) error {
 	d.nm = in.Name()
-	d.csv = csv.NewReader(in.Unwrap())
+	d.csv = newReader(in.Unwrap())
 	if d.Quote < 0 {
 		d.Quote = '"'
 	}
 	if d.Comma < 0 {
 		d.Comma = ','
 	}
-	d.csv.Comma = d.Comma
-	d.csv.Comment = d.Comment
-	d.csv.TrimLeadingSpace = d.TrimLeadingSpace
-	d.lno = 1
+	if d.Escape < 0 {
+		d.Escape = 0
+	}
+	if d.Comment < 0 {
+		d.Comment = 0
+	}
+	d.csv.comma = d.Comma
+	d.csv.quote = d.Quote
+	d.csv.escape = d.Escape
+	d.csv.comment = d.Comment
+	d.csv.trim = d.TrimLeadingSpace
 	d.resumed = false
 	return nil
 }
@@ -71,7 +77,7 @@ func (d *Decoder) ReadHeader(_ string, possibleHeader []string) ([]string, error
 		return d.hdr, nil
 	}
 
-	hdr, err := d.csv.Read()
+	hdr, err := d.csv.read()
 	if err != nil {
 		if err == io.EOF {
 			return nil, format.ErrNoHeader
@@ -82,35 +88,33 @@ func (d *Decoder) ReadHeader(_ string, possibleHeader []string) ([]string, error
 		return nil, format.NewDimErr(d.ctx(), len(d.hdr), len(hdr))
 	}
 	if len(d.hdr) == 0 {
-		d.hdr = hdr
+		//copy since the reader reuses its record
+		d.hdr = append([]string(nil), hdr...)
 	}
 	//preallocate scratch space
 	if cap(d.acc) < len(d.hdr) {
 		d.acc = make([]*string, 0, len(d.hdr))
 	}
-	d.lno++
 	return d.hdr, nil
 }
 
 //Skip rows.
 func (d *Decoder) Skip(rows int) error {
 	for i := 0; i < rows; i++ {
-		_, err := d.csv.Read()
+		_, err := d.csv.read()
 		if err != nil {
 			return wrap(d, err)
 		}
-		d.lno++
 	}
 	return nil
 }
 
 //ReadRow reads a row from the CSV and handles NULL decoding.
 func (d *Decoder) ReadRow() ([]*string, error) {
-	row, err := d.csv.Read()
+	row, err := d.csv.read()
 	if err != nil {
 		return nil, wrap(d, err)
 	}
-	d.lno++
 	if d.Strict && len(row) != len(d.hdr) {
 		return nil, format.NewDimErr(d.ctx(), len(d.hdr), len(row))
 	}
@@ -131,6 +135,11 @@ func (d *Decoder) ReadRow() ([]*string, error) {
 	return d.acc, nil
 }
 
+//Line reports the line the last record read began on.
+func (d *Decoder) Line() int {
+	return d.csv.line
+}
+
 //Reset decouples the CSV reader and zeroes internal scratch space.
 func (d *Decoder) Reset() error {
 	d.resumed = true
@@ -1,24 +1,26 @@
 package csvfmt
 
 import (
-	"encoding/csv"
 	"fmt"
 
 	"github.com/jimmyfrasche/etlite/internal/device"
 	"github.com/jimmyfrasche/etlite/internal/format"
 	"github.com/jimmyfrasche/etlite/internal/internal/null"
+	"github.com/jimmyfrasche/etlite/internal/internal/value"
 )
 
 //Encoder is a CSV encoder.
 type Encoder struct {
 	Null    null.Encoding
 	Comma   rune
-	Quote   rune //unused
+	Quote   rune
+	Escape  rune //If nonzero and not Quote, used to escape Quote instead of doubling it
 	UseCRLF bool
 
 	NoHeader bool
 
-	csv *csv.Writer
+	w   device.Writer
+	csv *writer
 	acc []string
 
 	nm  string
@@ -39,15 +41,17 @@ func (*Encoder) Name() string {
 
 func (e *Encoder) Init(w device.Writer) error {
 	e.nm = w.Name()
-	e.csv = csv.NewWriter(w.Unwrap())
 	if e.Quote < 0 {
 		e.Quote = '"'
 	}
 	if e.Comma < 0 {
 		e.Comma = ','
 	}
-	e.csv.Comma = e.Comma
-	e.csv.UseCRLF = e.UseCRLF
+	if e.Escape < 0 {
+		e.Escape = 0
+	}
+	e.w = w
+	e.csv = newWriter(w.Unwrap(), e.Comma, e.Quote, e.Escape, e.UseCRLF)
 	e.resumed = false
 	e.lno = 1
 	return nil
@@ -62,7 +66,7 @@ func (e *Encoder) WriteHeader(_ string, hdr []string) error {
 		return nil
 	}
 
-	if err := e.csv.Write(hdr); err != nil {
+	if err := e.csv.write(hdr); err != nil {
 		return wrap(e, err)
 	}
 	e.lno++
@@ -70,24 +74,23 @@ func (e *Encoder) WriteHeader(_ string, hdr []string) error {
 }
 
 //WriteRow writes a row to the CSV, handling all NULL encodings.
-func (e *Encoder) WriteRow(row []*string) error {
+func (e *Encoder) WriteRow(row []value.Value) error {
 	e.acc = e.acc[:0]
 	for _, col := range row {
-		e.acc = append(e.acc, e.Null.Decode(col))
+		e.acc = append(e.acc, e.Null.DecodeValue(col))
 	}
 	e.lno++
-	return wrap(e, e.csv.Write(e.acc))
+	return wrap(e, e.csv.write(e.acc))
 }
 
-//Reset flushes and decouples the CSV writer.
+//Reset flushes the CSV writer.
 func (e *Encoder) Reset() error {
-	e.csv.Flush()
 	e.resumed = true
-	return wrap(e, e.csv.Error())
+	return wrap(e, e.w.Flush())
 }
 
 //Close is a no-op.
 func (e *Encoder) Close() error {
-	e.csv = nil
+	e.w, e.csv = nil, nil
 	return nil
 }
@@ -1,8 +1,6 @@
 package csvfmt
 
 import (
-	"encoding/csv"
-
 	"github.com/jimmyfrasche/etlite/internal/format"
 	"github.com/jimmyfrasche/etlite/internal/internal/errsys"
 )
@@ -15,14 +13,8 @@ func wrap(c interface {
 	}
 	ctx := c.ctx()
 	switch err {
-	case csv.ErrTrailingComma,
-		csv.ErrBareQuote,
-		csv.ErrQuote,
-		csv.ErrFieldCount:
-		return format.Wrap(ctx, err)
-	}
-	if _, ok := err.(*csv.ParseError); ok {
-		return format.Wrap(ctx, err)
+	case errQuote, errAfterQuote, errEscape:
+		return format.WrapRow(ctx, err)
 	}
 	//everything else comes from I/O
 	return errsys.Wrap(err)
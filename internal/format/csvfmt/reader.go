@@ -0,0 +1,206 @@
+package csvfmt
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"unicode"
+)
+
+var (
+	errQuote      = errors.New("quoted field not terminated")
+	errAfterQuote = errors.New("unexpected character after closing quote")
+	errEscape     = errors.New("escape character at end of input")
+)
+
+//reader reads records from CSV, with a configurable quote and escape.
+//
+//Either \n or \r\n ends a record.
+type reader struct {
+	r *bufio.Reader
+
+	comma, quote, escape, comment rune
+	trim                          bool
+
+	line  int //line the current record started on
+	lines int //lines read so far
+
+	back    rune //rune put back by unread
+	hasBack bool
+
+	field  []rune
+	record []string
+}
+
+func newReader(r *bufio.Reader) *reader {
+	return &reader{
+		r:     r,
+		comma: ',',
+		quote: '"',
+		lines: 1,
+	}
+}
+
+func (r *reader) next() (rune, error) {
+	if r.hasBack {
+		r.hasBack = false
+		return r.back, nil
+	}
+	c, _, err := r.r.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	if c == '\r' {
+		//normalize \r\n to \n, leaving other \r alone
+		n, _, err := r.r.ReadRune()
+		if err == nil {
+			if n == '\n' {
+				c = '\n'
+			} else {
+				_ = r.r.UnreadRune()
+			}
+		}
+	}
+	if c == '\n' {
+		r.lines++
+	}
+	return c, nil
+}
+
+//unread c, which must not be a newline, so it is returned by the next call to next.
+func (r *reader) unread(c rune) {
+	r.back, r.hasBack = c, true
+}
+
+//skipLine discards the rest of the current line.
+func (r *reader) skipLine() error {
+	for {
+		c, err := r.next()
+		if err != nil {
+			return err
+		}
+		if c == '\n' {
+			return nil
+		}
+	}
+}
+
+//read the next record, skipping empty lines and comments.
+//The returned slice is only valid until the next call.
+func (r *reader) read() ([]string, error) {
+	//find the start of the next record
+	for {
+		c, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		if c == '\n' {
+			continue
+		}
+		if r.comment != 0 && c == r.comment {
+			if err := r.skipLine(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		r.unread(c)
+		break
+	}
+	r.line = r.lines
+
+	r.record = r.record[:0]
+	for {
+		eol, err := r.readField()
+		if err == io.EOF {
+			eol, err = true, nil
+		}
+		if err == errAfterQuote {
+			//skip the rest of the record so that reading may continue
+			if serr := r.skipLine(); serr != nil && serr != io.EOF {
+				return nil, serr
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		r.record = append(r.record, string(r.field))
+		if eol {
+			return r.record, nil
+		}
+	}
+}
+
+//readField reads a field into r.field and reports whether it ended the record.
+func (r *reader) readField() (eol bool, err error) {
+	r.field = r.field[:0]
+
+	c, err := r.next()
+	if r.trim {
+		for err == nil && c != '\n' && c != r.comma && unicode.IsSpace(c) {
+			c, err = r.next()
+		}
+	}
+	if err != nil {
+		return true, err
+	}
+
+	if r.quote == 0 || c != r.quote {
+		//unquoted field
+		for {
+			switch {
+			case c == r.comma:
+				return false, nil
+			case c == '\n':
+				return true, nil
+			}
+			r.field = append(r.field, c)
+			c, err = r.next()
+			if err != nil {
+				return true, err
+			}
+		}
+	}
+
+	//quoted field
+	for {
+		c, err = r.next()
+		if err == io.EOF {
+			return true, errQuote
+		}
+		if err != nil {
+			return true, err
+		}
+
+		switch {
+		case r.escape != 0 && r.escape != r.quote && c == r.escape:
+			c, err = r.next()
+			if err == io.EOF {
+				return true, errEscape
+			}
+			if err != nil {
+				return true, err
+			}
+			r.field = append(r.field, c)
+
+		case c == r.quote:
+			c, err = r.next()
+			if err != nil {
+				return true, err
+			}
+			switch {
+			case c == r.quote && (r.escape == 0 || r.escape == r.quote):
+				//doubled quote
+				r.field = append(r.field, c)
+			case c == r.comma:
+				return false, nil
+			case c == '\n':
+				return true, nil
+			default:
+				return true, errAfterQuote
+			}
+
+		default:
+			r.field = append(r.field, c)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package csvfmt
+
+import (
+	"bufio"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//writer writes records as CSV, with a configurable quote and escape.
+type writer struct {
+	w *bufio.Writer
+
+	comma, quote, escape rune
+	crlf                 bool
+
+	special string //runes that require a field to be quoted
+}
+
+func newWriter(w *bufio.Writer, comma, quote, escape rune, crlf bool) *writer {
+	special := "\r\n" + string(comma) + string(quote)
+	if escape != 0 {
+		special += string(escape)
+	}
+	return &writer{
+		w:       w,
+		comma:   comma,
+		quote:   quote,
+		escape:  escape,
+		crlf:    crlf,
+		special: special,
+	}
+}
+
+func (w *writer) needsQuotes(f string) bool {
+	if f == "" {
+		return false
+	}
+	if strings.ContainsAny(f, w.special) {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(f)
+	return unicode.IsSpace(r)
+}
+
+//write a record.
+func (w *writer) write(record []string) error {
+	for i, f := range record {
+		if i > 0 {
+			if _, err := w.w.WriteRune(w.comma); err != nil {
+				return err
+			}
+		}
+		if err := w.field(f); err != nil {
+			return err
+		}
+	}
+	eol := "\n"
+	if w.crlf {
+		eol = "\r\n"
+	}
+	_, err := w.w.WriteString(eol)
+	return err
+}
+
+func (w *writer) field(f string) error {
+	if !w.needsQuotes(f) {
+		_, err := w.w.WriteString(f)
+		return err
+	}
+
+	if _, err := w.w.WriteRune(w.quote); err != nil {
+		return err
+	}
+	for _, c := range f {
+		var err error
+		switch {
+		case c == w.quote || (w.escape != 0 && c == w.escape):
+			esc := w.escape
+			if esc == 0 {
+				esc = w.quote
+			}
+			if _, err = w.w.WriteRune(esc); err == nil {
+				_, err = w.w.WriteRune(c)
+			}
+		case c == '\n' && w.crlf:
+			_, err = w.w.WriteString("\r\n")
+		case c == '\r' && w.crlf:
+			//dropped so that \r\n is not doubled up
+		default:
+			_, err = w.w.WriteRune(c)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err := w.w.WriteRune(w.quote)
+	return err
+}
@@ -56,3 +56,13 @@ type Decoder interface {
 	//Close is called when the Decoder will never be used again.
 	Close() error
 }
+
+//LineDecoder is a Decoder of a line-oriented format
+//that reports where rows are in the input.
+type LineDecoder interface {
+	Decoder
+
+	//Line reports the line of the input that the row last read,
+	//or that failed to be read, began on, counting from 1.
+	Line() int
+}
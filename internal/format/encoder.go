@@ -1,6 +1,9 @@
 package format
 
-import "github.com/jimmyfrasche/etlite/internal/device"
+import (
+	"github.com/jimmyfrasche/etlite/internal/device"
+	"github.com/jimmyfrasche/etlite/internal/internal/value"
+)
 
 //Encoder encodes an SQLite table as text.
 //For each table,
@@ -22,7 +25,10 @@ type Encoder interface {
 	//If the format requires the name of a data frame to write and none is provided,
 	//WriteHeader must return ErrFrameRequired.
 	WriteHeader(frame string, header []string) error
-	WriteRow([]*string) error
+
+	//WriteRow is given each value with its storage class
+	//so that formats with types may preserve them.
+	WriteRow([]value.Value) error
 	Reset() error
 	Close() error
 }
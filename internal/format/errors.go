@@ -37,6 +37,7 @@ func (d *dimError) Error() string {
 type fmtError struct {
 	ctx string
 	e   error
+	row bool
 }
 
 //Wrap an error as a format error.
@@ -50,6 +51,19 @@ func Wrap(ctx string, err error) error {
 	}
 }
 
+//WrapRow wraps an error in a single row of the input as a format error.
+//The Decoder must be able to continue reading from the next row.
+func WrapRow(ctx string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &fmtError{
+		ctx: ctx,
+		e:   err,
+		row: true,
+	}
+}
+
 func (f *fmtError) Error() string {
 	if f.ctx == "" {
 		return f.e.Error()
@@ -71,3 +85,15 @@ func IsFormatErr(e error) bool {
 	}
 	return false
 }
+
+//IsRowErr returns whether e is an error in a single row of the input,
+//after which the Decoder may continue reading from the next row.
+func IsRowErr(e error) bool {
+	switch e := e.(type) {
+	case *dimError:
+		return true
+	case *fmtError:
+		return e.row
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+//Package fmttest provides fake devices for testing formats.
+package fmttest
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+//Reader is a device.Reader over an io.Reader.
+type Reader struct {
+	*bufio.Reader
+}
+
+//NewReader creates a Reader that reads from r.
+func NewReader(r io.Reader) Reader {
+	return Reader{bufio.NewReader(r)}
+}
+
+//NewStringReader creates a Reader that reads s.
+func NewStringReader(s string) Reader {
+	return NewReader(strings.NewReader(s))
+}
+
+func (r Reader) Close() error {
+	return nil
+}
+
+func (r Reader) Unwrap() *bufio.Reader {
+	return r.Reader
+}
+
+func (r Reader) Name() string {
+	return "test"
+}
+
+//Writer is a device.Writer over an io.Writer.
+type Writer struct {
+	*bufio.Writer
+}
+
+//NewWriter creates a Writer that writes to w.
+func NewWriter(w io.Writer) Writer {
+	return Writer{bufio.NewWriter(w)}
+}
+
+func (w Writer) Close() error {
+	return w.Flush()
+}
+
+func (w Writer) Unwrap() *bufio.Writer {
+	return w.Writer
+}
+
+func (w Writer) Name() string {
+	return "test"
+}
+
+func (w Writer) Cancel() {}
+
+//Str formats a nullable value, displaying NULL as <nil>.
+func Str(s *string) string {
+	if s == nil {
+		return "<nil>"
+	}
+	return *s
+}
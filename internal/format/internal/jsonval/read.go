@@ -0,0 +1,115 @@
+//Package jsonval converts between JSON values and
+//the text representation of SQLite values used by formats.
+package jsonval
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/jimmyfrasche/etlite/internal/internal/errint"
+)
+
+//Reader reads rows of values from a json.Decoder.
+//
+//Errors from the underlying json.Decoder are returned as is,
+//except that a premature io.EOF is reported as io.ErrUnexpectedEOF.
+type Reader struct {
+	Dec *json.Decoder
+	buf bytes.Buffer
+}
+
+//Object reads the members of an object
+//whose opening { has already been read, and its closing }.
+func (r *Reader) Object() (keys []string, vals []*string, err error) {
+	for r.Dec.More() {
+		t, err := r.Dec.Token()
+		if err != nil {
+			return nil, nil, noEOF(err)
+		}
+		k, ok := t.(string)
+		if !ok {
+			//the json package should never let this happen
+			return nil, nil, errint.Newf("object key is not a string: %v", t)
+		}
+		v, err := r.Value()
+		if err != nil {
+			return nil, nil, err
+		}
+		keys = append(keys, k)
+		vals = append(vals, v)
+	}
+	if err := r.end('}'); err != nil {
+		return nil, nil, err
+	}
+	return keys, vals, nil
+}
+
+//Array reads the elements of an array
+//whose opening [ has already been read, and its closing ].
+func (r *Reader) Array() (row []*string, err error) {
+	for r.Dec.More() {
+		v, err := r.Value()
+		if err != nil {
+			return nil, err
+		}
+		row = append(row, v)
+	}
+	if err := r.end(']'); err != nil {
+		return nil, err
+	}
+	return row, nil
+}
+
+func (r *Reader) end(d json.Delim) error {
+	t, err := r.Dec.Token()
+	if err != nil {
+		return noEOF(err)
+	}
+	if t != d {
+		//the json package should never let this happen
+		return errint.Newf("expected %v got %v", d, t)
+	}
+	return nil
+}
+
+//Value reads a single value as text.
+//
+//Strings are unquoted, numbers are as written,
+//booleans are 1 or 0, as in SQLite, and null is NULL.
+//Objects and arrays are returned as compact JSON.
+func (r *Reader) Value() (*string, error) {
+	var raw json.RawMessage
+	if err := r.Dec.Decode(&raw); err != nil {
+		return nil, noEOF(err)
+	}
+	var s string
+	switch raw[0] {
+	case 'n':
+		return nil, nil
+	case 't':
+		s = "1"
+	case 'f':
+		s = "0"
+	case '"':
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, err
+		}
+	case '{', '[':
+		r.buf.Reset()
+		if err := json.Compact(&r.buf, raw); err != nil {
+			return nil, err
+		}
+		s = r.buf.String()
+	default:
+		s = string(raw)
+	}
+	return &s, nil
+}
+
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,131 @@
+package jsonval
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"strconv"
+
+	"github.com/jimmyfrasche/etlite/internal/internal/value"
+)
+
+//Writer encodes rows of values as JSON.
+//
+//The zero value is not ready for use, see NewWriter.
+type Writer struct {
+	row *bytes.Buffer //scratch space for the current row
+	str *bytes.Buffer //scratch space for encoding strings
+	enc *json.Encoder //encodes strings into str
+}
+
+//NewWriter creates a Writer.
+func NewWriter() *Writer {
+	w := &Writer{
+		row: &bytes.Buffer{},
+		str: &bytes.Buffer{},
+	}
+	w.enc = json.NewEncoder(w.str)
+	w.enc.SetEscapeHTML(false)
+	return w
+}
+
+//Keys returns the JSON encoding of each column name in hdr,
+//for use with Object.
+func (w *Writer) Keys(hdr []string) ([][]byte, error) {
+	keys := make([][]byte, len(hdr))
+	for i, h := range hdr {
+		k, err := w.quote(h)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = append([]byte(nil), k...)
+	}
+	return keys, nil
+}
+
+//Object encodes row as an object whose members are named by keys.
+//
+//The returned slice is only valid until the next call to w.
+func (w *Writer) Object(keys [][]byte, row []value.Value) ([]byte, error) {
+	return w.encode('{', '}', keys, row)
+}
+
+//Array encodes row as an array.
+//
+//The returned slice is only valid until the next call to w.
+func (w *Writer) Array(row []value.Value) ([]byte, error) {
+	return w.encode('[', ']', nil, row)
+}
+
+//Header encodes the JSON encoded column names in keys as an array.
+//
+//The returned slice is only valid until the next call to w.
+func (w *Writer) Header(keys [][]byte) []byte {
+	w.row.Reset()
+	w.row.WriteByte('[')
+	for i, k := range keys {
+		if i > 0 {
+			w.row.WriteByte(',')
+		}
+		w.row.Write(k)
+	}
+	w.row.WriteByte(']')
+	return w.row.Bytes()
+}
+
+func (w *Writer) encode(open, close byte, keys [][]byte, row []value.Value) ([]byte, error) {
+	w.row.Reset()
+	w.row.WriteByte(open)
+	for i, v := range row {
+		if i > 0 {
+			w.row.WriteByte(',')
+		}
+		if keys != nil && i < len(keys) {
+			w.row.Write(keys[i])
+			w.row.WriteByte(':')
+		}
+		if err := w.value(v); err != nil {
+			return nil, err
+		}
+	}
+	w.row.WriteByte(close)
+	return w.row.Bytes(), nil
+}
+
+//value encodes v according to its storage class.
+func (w *Writer) value(v value.Value) error {
+	s := v.Text
+	switch v.Kind {
+	case value.Null:
+		w.row.WriteString("null")
+		return nil
+	case value.Integer, value.Real:
+		//SQLite renders numbers as valid JSON,
+		//except for infinities, which are left as strings.
+		if f, err := strconv.ParseFloat(s, 64); err == nil && !math.IsInf(f, 0) {
+			w.row.WriteString(s)
+			return nil
+		}
+	case value.Blob:
+		s = base64.StdEncoding.EncodeToString([]byte(s))
+	}
+	q, err := w.quote(s)
+	if err != nil {
+		return err
+	}
+	w.row.Write(q)
+	return nil
+}
+
+//quote s as a JSON string.
+//The returned slice is only valid until the next call.
+func (w *Writer) quote(s string) ([]byte, error) {
+	w.str.Reset()
+	if err := w.enc.Encode(s); err != nil {
+		return nil, err
+	}
+	//Encode appends a newline
+	b := w.str.Bytes()
+	return b[:len(b)-1], nil
+}
@@ -0,0 +1,417 @@
+package jsonfmt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/jimmyfrasche/etlite/internal/device"
+	"github.com/jimmyfrasche/etlite/internal/format"
+	"github.com/jimmyfrasche/etlite/internal/format/internal/jsonval"
+)
+
+//Decoder is a JSON decoder.
+type Decoder struct {
+	Strict bool //When true reports an error if a row has more or less fields than the header
+
+	br   *bufio.Reader //the device, for peeking past the top decoder
+	top  *json.Decoder //the device
+	dec  *json.Decoder //the current frame or the device
+	vals *jsonval.Reader
+	nm   string
+	rec  int
+
+	frames map[string]json.RawMessage //object of tables, once read
+	frame  string                     //name of the current frame
+
+	hdr   []string
+	index map[string]int //column of each key in the header, for objects
+
+	inArray bool //whether we are in the middle of a top level array
+	arrays  bool //whether the current array is an array of arrays
+	first   bool //whether acc holds the first row, read to derive the header
+
+	acc []*string
+
+	resumed bool
+}
+
+func (d *Decoder) ctx() string {
+	if d.frame != "" {
+		return fmt.Sprintf("%s[%s]:%d:", d.nm, d.frame, d.rec)
+	}
+	return fmt.Sprintf("%s:%d:", d.nm, d.rec)
+}
+
+var _ format.Decoder = (*Decoder)(nil)
+
+func (*Decoder) Name() string {
+	return "JSON"
+}
+
+func (d *Decoder) Init(r device.Reader) error {
+	d.nm = r.Name()
+	d.br = bufio.NewReader(r.Unwrap())
+	d.top = json.NewDecoder(d.br)
+	d.use(d.top, "")
+	d.frames = nil
+	d.rec = 0
+	d.inArray = false
+	d.first = false
+	d.resumed = false
+	return nil
+}
+
+//ReadHeader reads the start of the next top level array, or,
+//if a frame is provided, the start of the array in that member
+//of the top level object, and derives the header from its first element,
+//unless a header is provided.
+//
+//If the previous table stopped before the end of its array,
+//and the frame has not changed, the array is continued with the same header.
+//
+//If no frame is provided and the top level is an object,
+//it returns format.ErrFrameRequired without losing the object,
+//so that it may be called again with a frame.
+func (d *Decoder) ReadHeader(frame string, header []string) ([]string, error) {
+	if d.resumed && d.inArray && frame == d.frame {
+		if len(header) != 0 {
+			d.setHeader(header)
+		}
+		return d.hdr, nil
+	}
+
+	if err := d.selectFrame(frame); err != nil {
+		return nil, err
+	}
+
+	if frame == "" {
+		//read an object of tables whole so that it may be retried with a frame
+		if b, err := d.peek(); err == nil && b == '{' {
+			if err := d.readFrames(frame); err != nil {
+				return nil, err
+			}
+			return nil, format.ErrFrameRequired
+		}
+	}
+
+	t, err := d.dec.Token()
+	if err == io.EOF {
+		if len(header) != 0 {
+			//no more tables but we do not need the input to derive a header
+			d.setHeader(header)
+			return d.hdr, nil
+		}
+		return nil, format.ErrNoHeader
+	}
+	if err != nil {
+		return nil, wrap(d, err)
+	}
+	if t != json.Delim('[') {
+		return nil, format.Wrap(d.ctx(), fmt.Errorf("expected [, got %v", t))
+	}
+	d.inArray = true
+	d.rec = 0
+
+	if !d.dec.More() {
+		//empty table
+		if err := d.endArray(); err != nil {
+			return nil, err
+		}
+		if len(header) == 0 {
+			return nil, format.ErrNoHeader
+		}
+		d.setHeader(header)
+		return d.hdr, nil
+	}
+
+	t, err = d.dec.Token()
+	if err != nil {
+		return nil, wrap(d, err)
+	}
+	d.rec++
+	switch t {
+	default:
+		return nil, format.Wrap(d.ctx(), fmt.Errorf("expected object or array, got %v", t))
+
+	case json.Delim('{'):
+		d.arrays = false
+		keys, vals, err := d.readObject()
+		if err != nil {
+			return nil, err
+		}
+		if len(header) == 0 {
+			header = keys
+		}
+		d.setHeader(header)
+		if err := d.fill(keys, vals); err != nil {
+			return nil, err
+		}
+		d.first = true
+
+	case json.Delim('['):
+		d.arrays = true
+		row, err := d.readArray()
+		if err != nil {
+			return nil, err
+		}
+		if len(header) == 0 {
+			//first row is the header, NULL names are as good as empty names
+			header = make([]string, len(row))
+			for i, v := range row {
+				if v != nil {
+					header[i] = *v
+				}
+			}
+		}
+		d.setHeader(header)
+	}
+
+	return d.hdr, nil
+}
+
+//selectFrame switches to the array in the named member of
+//the top level object, reading the object if necessary,
+//or to the top level if no frame is named.
+func (d *Decoder) selectFrame(frame string) error {
+	if frame == "" {
+		if d.frames != nil {
+			return format.ErrFrameRequired
+		}
+		if d.dec != d.top {
+			d.use(d.top, "")
+		}
+		return nil
+	}
+
+	if d.frames == nil {
+		if err := d.readFrames(frame); err != nil {
+			return err
+		}
+	}
+
+	member, ok := d.frames[frame]
+	if !ok {
+		return format.Wrap(d.ctx(), fmt.Errorf("no frame named %q", frame))
+	}
+	d.use(json.NewDecoder(bytes.NewReader(member)), frame)
+	d.inArray = false
+	return nil
+}
+
+//readFrames reads the top level object of tables into d.frames.
+func (d *Decoder) readFrames(frame string) error {
+	var raw json.RawMessage
+	if err := d.top.Decode(&raw); err != nil {
+		if err == io.EOF {
+			return format.Wrap(d.ctx(), fmt.Errorf("no frame named %q", frame))
+		}
+		return wrap(d, err)
+	}
+	if raw[0] != '{' {
+		return format.Wrap(d.ctx(), fmt.Errorf("FRAME %s requires an object keyed by table name", frame))
+	}
+	if err := json.Unmarshal(raw, &d.frames); err != nil {
+		return wrap(d, err)
+	}
+	return nil
+}
+
+//peek returns the first byte of the next top level value without reading it.
+func (d *Decoder) peek() (byte, error) {
+	buf, err := ioutil.ReadAll(d.top.Buffered())
+	if err != nil {
+		return 0, err
+	}
+	for _, b := range buf {
+		if !isSpace(b) {
+			return b, nil
+		}
+	}
+	//the rest of the buffer is white space, so look past it
+	for {
+		p, err := d.br.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		if !isSpace(p[0]) {
+			return p[0], nil
+		}
+		if _, err := d.br.ReadByte(); err != nil {
+			return 0, err
+		}
+	}
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
+
+func (d *Decoder) use(dec *json.Decoder, frame string) {
+	d.dec = dec
+	d.vals = &jsonval.Reader{Dec: dec}
+	d.frame = frame
+}
+
+func (d *Decoder) setHeader(header []string) {
+	d.hdr = header
+	d.index = make(map[string]int, len(header))
+	for i, h := range header {
+		if _, ok := d.index[h]; !ok {
+			d.index[h] = i
+		}
+	}
+	if cap(d.acc) < len(header) {
+		d.acc = make([]*string, len(header))
+	}
+	d.acc = d.acc[:len(header)]
+}
+
+//Skip rows.
+func (d *Decoder) Skip(rows int) error {
+	for i := 0; i < rows; i++ {
+		if _, err := d.ReadRow(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+//ReadRow reads the next element of the current array.
+func (d *Decoder) ReadRow() ([]*string, error) {
+	if d.first {
+		d.first = false
+		return d.acc, nil
+	}
+	if !d.inArray {
+		return nil, io.EOF
+	}
+	if !d.dec.More() {
+		if err := d.endArray(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+
+	t, err := d.dec.Token()
+	if err != nil {
+		return nil, wrap(d, err)
+	}
+	d.rec++
+
+	if !d.arrays {
+		if t != json.Delim('{') {
+			return nil, format.Wrap(d.ctx(), fmt.Errorf("expected object, got %v", t))
+		}
+		keys, vals, err := d.readObject()
+		if err != nil {
+			return nil, err
+		}
+		if err := d.fill(keys, vals); err != nil {
+			return nil, err
+		}
+		return d.acc, nil
+	}
+
+	if t != json.Delim('[') {
+		return nil, format.Wrap(d.ctx(), fmt.Errorf("expected array, got %v", t))
+	}
+	row, err := d.readArray()
+	if err != nil {
+		return nil, err
+	}
+	if d.Strict && len(row) != len(d.hdr) {
+		return nil, format.NewDimErr(d.ctx(), len(d.hdr), len(row))
+	}
+	if len(row) > len(d.hdr) {
+		//not in strict mode, ignore extra fields
+		row = row[:len(d.hdr)]
+	}
+	n := copy(d.acc, row)
+	for i := n; i < len(d.acc); i++ {
+		//not in strict mode, need to add in additional nulls
+		d.acc[i] = nil
+	}
+	return d.acc, nil
+}
+
+//Reset the decoder for reuse.
+//
+//The remainder of the current array, if any,
+//is left for the next table on this device.
+func (d *Decoder) Reset() error {
+	d.resumed = true
+	return nil
+}
+
+//Close the decoder.
+func (d *Decoder) Close() error {
+	d.br, d.top, d.dec, d.vals = nil, nil, nil, nil
+	d.frames, d.frame = nil, ""
+	d.hdr, d.index = nil, nil
+	for i := range d.acc {
+		d.acc[i] = nil
+	}
+	d.acc = d.acc[:0]
+	return nil
+}
+
+func (d *Decoder) endArray() error {
+	d.inArray = false
+	t, err := d.dec.Token()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return wrap(d, err)
+	}
+	if t != json.Delim(']') {
+		return format.Wrap(d.ctx(), fmt.Errorf("expected ], got %v", t))
+	}
+	return nil
+}
+
+//fill the scratch row with the values of an object.
+func (d *Decoder) fill(keys []string, vals []*string) error {
+	for i := range d.acc {
+		d.acc[i] = nil
+	}
+	seen := 0
+	for i, k := range keys {
+		col, ok := d.index[k]
+		if !ok {
+			if d.Strict {
+				return format.WrapRow(d.ctx(), fmt.Errorf("unexpected key %q", k))
+			}
+			continue
+		}
+		d.acc[col] = vals[i]
+		seen++
+	}
+	if d.Strict && seen != len(d.hdr) {
+		return format.NewDimErr(d.ctx(), len(d.hdr), seen)
+	}
+	return nil
+}
+
+func (d *Decoder) readObject() ([]string, []*string, error) {
+	keys, vals, err := d.vals.Object()
+	if err != nil {
+		return nil, nil, wrap(d, err)
+	}
+	return keys, vals, nil
+}
+
+func (d *Decoder) readArray() ([]*string, error) {
+	row, err := d.vals.Array()
+	if err != nil {
+		return nil, wrap(d, err)
+	}
+	return row, nil
+}
@@ -0,0 +1,19 @@
+//Package jsonfmt defines encoding and decoding of the JSON format.
+//
+//A table is a JSON array whose elements are either
+//objects, keyed by the column names in the header,
+//or arrays, with the header as the first element.
+//
+//The decoder recognizes either form automatically.
+//The encoder writes objects unless configured otherwise.
+//
+//Multiple tables on the same device are written as
+//a sequence of top level arrays.
+//
+//Tables may also be named frames in a top level object keyed by table name,
+//such as
+//	{"users": [...], "orders": [...]}
+//When a frame is requested the decoder reads the whole object
+//and the frames may be imported in any order.
+//The encoder writes consecutive tables with frames to the same object.
+package jsonfmt
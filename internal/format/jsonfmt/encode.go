@@ -0,0 +1,179 @@
+package jsonfmt
+
+import (
+	"fmt"
+
+	"github.com/jimmyfrasche/etlite/internal/device"
+	"github.com/jimmyfrasche/etlite/internal/format"
+	"github.com/jimmyfrasche/etlite/internal/format/internal/jsonval"
+	"github.com/jimmyfrasche/etlite/internal/internal/errsys"
+	"github.com/jimmyfrasche/etlite/internal/internal/value"
+)
+
+//Encoder is a JSON encoder.
+type Encoder struct {
+	Arrays bool //Encode rows as arrays, with the header as the first row, instead of objects.
+
+	w    device.Writer
+	vals *jsonval.Writer
+	keys [][]byte //JSON encoded header
+	rows int
+
+	inObject bool            //whether we are in the middle of a top level object of frames
+	frames   map[string]bool //frames written to the current object
+}
+
+var _ format.Encoder = (*Encoder)(nil)
+
+func (e *Encoder) ctx() string {
+	return fmt.Sprintf("%s:%d:", e.w.Name(), e.rows)
+}
+
+func (*Encoder) Name() string {
+	return "JSON"
+}
+
+func (e *Encoder) Init(w device.Writer) error {
+	e.w = w
+	e.inObject, e.frames = false, nil
+	if e.vals == nil {
+		e.vals = jsonval.NewWriter()
+	}
+	return nil
+}
+
+//WriteHeader starts a new array and,
+//if encoding arrays, writes the header as its first element.
+//
+//If a frame is provided, the array is written as the member of
+//a top level object with that name.
+//Consecutive frames are written to the same object.
+func (e *Encoder) WriteHeader(frame string, hdr []string) error {
+	e.rows = 0
+	keys, err := e.vals.Keys(hdr)
+	if err != nil {
+		return format.Wrap(e.ctx(), err)
+	}
+	e.keys = keys
+
+	if frame == "" {
+		if err := e.endObject(); err != nil {
+			return err
+		}
+		if err := e.write("["); err != nil {
+			return err
+		}
+	} else {
+		if e.frames[frame] {
+			return format.Wrap(e.ctx(), fmt.Errorf("frame %q already written", frame))
+		}
+		name, err := e.vals.Keys([]string{frame})
+		if err != nil {
+			return format.Wrap(e.ctx(), err)
+		}
+
+		sep := ",\n"
+		if !e.inObject {
+			sep = "{\n"
+			e.inObject = true
+			e.frames = map[string]bool{}
+		}
+		e.frames[frame] = true
+		if err := e.write(sep); err != nil {
+			return err
+		}
+		if err := e.writeBytes(name[0]); err != nil {
+			return err
+		}
+		if err := e.write(":["); err != nil {
+			return err
+		}
+	}
+
+	if !e.Arrays {
+		return nil
+	}
+	if err := e.write("\n"); err != nil {
+		return err
+	}
+	e.rows++
+	return e.writeBytes(e.vals.Header(e.keys))
+}
+
+//WriteRow writes a row as an object or array.
+//NULL is encoded as null, INTEGER and REAL as numbers,
+//BLOB as a base64 string, and TEXT as a string.
+func (e *Encoder) WriteRow(row []value.Value) error {
+	sep := "\n"
+	if e.rows > 0 {
+		sep = ",\n"
+	}
+	if err := e.write(sep); err != nil {
+		return err
+	}
+
+	var (
+		b   []byte
+		err error
+	)
+	if e.Arrays {
+		b, err = e.vals.Array(row)
+	} else {
+		b, err = e.vals.Object(e.keys, row)
+	}
+	if err != nil {
+		return format.Wrap(e.ctx(), err)
+	}
+
+	e.rows++
+	return e.writeBytes(b)
+}
+
+//Reset closes the array and flushes the output.
+func (e *Encoder) Reset() error {
+	end := "\n]\n"
+	if e.inObject {
+		//the object may have more frames
+		end = "\n]"
+	}
+	if err := e.write(end); err != nil {
+		return err
+	}
+	return e.w.Flush()
+}
+
+//Close the encoder, closing the current object of frames, if any.
+func (e *Encoder) Close() error {
+	if e.inObject {
+		if err := e.endObject(); err != nil {
+			return err
+		}
+		if err := e.w.Flush(); err != nil {
+			return errsys.WrapWith(e.ctx(), err)
+		}
+	}
+	e.w = nil
+	return nil
+}
+
+func (e *Encoder) endObject() error {
+	if !e.inObject {
+		return nil
+	}
+	e.inObject, e.frames = false, nil
+	return e.write("\n}\n")
+}
+
+func (e *Encoder) write(s string) error {
+	if _, err := e.w.WriteString(s); err != nil {
+		return errsys.WrapWith(e.ctx(), err)
+	}
+	return nil
+}
+
+func (e *Encoder) writeBytes(p []byte) error {
+	if _, err := e.w.Write(p); err != nil {
+		return errsys.WrapWith(e.ctx(), err)
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package jsonfmt
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/jimmyfrasche/etlite/internal/format"
+	"github.com/jimmyfrasche/etlite/internal/internal/errsys"
+)
+
+func wrap(c interface {
+	ctx() string
+}, err error) error {
+	if err == nil {
+		return nil
+	}
+	ctx := c.ctx()
+	if err == io.ErrUnexpectedEOF {
+		return format.Wrap(ctx, err)
+	}
+	switch err.(type) {
+	case *json.SyntaxError, *json.UnmarshalTypeError:
+		return format.Wrap(ctx, err)
+	}
+	//everything else comes from I/O
+	return errsys.Wrap(err)
+}
@@ -0,0 +1,268 @@
+package jsonfmt
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/jimmyfrasche/etlite/internal/format"
+	"github.com/jimmyfrasche/etlite/internal/format/internal/fmttest"
+	"github.com/jimmyfrasche/etlite/internal/internal/value"
+)
+
+func readAll(t *testing.T, d *Decoder, in string, hdr []string) (string, [][]string) {
+	if err := d.Init(fmttest.NewStringReader(in)); err != nil {
+		t.Fatal(err)
+	}
+	h, err := d.ReadHeader("", hdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows [][]string
+	for {
+		row, err := d.ReadRow()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		var r []string
+		for _, v := range row {
+			r = append(r, fmttest.Str(v))
+		}
+		rows = append(rows, r)
+	}
+	if err := d.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	return strings.Join(h, ","), rows
+}
+
+func cmp(t *testing.T, ctx string, expected, got [][]string) {
+	if len(expected) != len(got) {
+		t.Fatalf("%s: expected %d rows got %d: %q", ctx, len(expected), len(got), got)
+	}
+	for i := range expected {
+		if e, g := strings.Join(expected[i], ","), strings.Join(got[i], ","); e != g {
+			t.Fatalf("%s: row %d expected %q got %q", ctx, i, e, g)
+		}
+	}
+}
+
+var decodeTests = []struct {
+	name, in string
+	hdr      []string
+	expHdr   string
+	rows     [][]string
+}{
+	{
+		name:   "objects",
+		in:     `[{"a": "x", "b": 1, "c": true}, {"c": null, "a": "y", "z": 0}, {"b": {"n": [1, 2]}}]`,
+		expHdr: "a,b,c",
+		rows: [][]string{
+			{"x", "1", "1"},
+			{"y", "<nil>", "<nil>"},
+			{"<nil>", `{"n":[1,2]}`, "<nil>"},
+		},
+	},
+	{
+		name:   "arrays",
+		in:     `[["a", "b"], ["x", 1.5], ["y"], ["z", false, "extra"]]`,
+		expHdr: "a,b",
+		rows: [][]string{
+			{"x", "1.5"},
+			{"y", "<nil>"},
+			{"z", "0"},
+		},
+	},
+	{
+		name:   "objects with header",
+		in:     `[{"a": 1, "b": 2}]`,
+		hdr:    []string{"b"},
+		expHdr: "b",
+		rows: [][]string{
+			{"2"},
+		},
+	},
+	{
+		name:   "empty with header",
+		in:     `[]`,
+		hdr:    []string{"a"},
+		expHdr: "a",
+	},
+}
+
+func TestDecoder(t *testing.T) {
+	for _, test := range decodeTests {
+		hdr, rows := readAll(t, &Decoder{}, test.in, test.hdr)
+		if hdr != test.expHdr {
+			t.Fatalf("%s: expected header %q got %q", test.name, test.expHdr, hdr)
+		}
+		cmp(t, test.name, test.rows, rows)
+	}
+}
+
+func TestDecoderStrict(t *testing.T) {
+	d := &Decoder{Strict: true}
+	if err := d.Init(fmttest.NewStringReader(`[{"a": 1}, {"a": 2, "b": 3}]`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.ReadHeader("", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.ReadRow(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.ReadRow(); err == nil {
+		t.Fatal("expected error on unexpected key")
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	hdr := []string{"a", "<b>"}
+	table := [][]value.Value{
+		{value.String("1"), {}},
+		{value.String(`"quoted" & <tagged>`), value.String("π")},
+	}
+	for _, arrays := range []bool{false, true} {
+		var buf bytes.Buffer
+		w := fmttest.NewWriter(&buf)
+		e := &Encoder{Arrays: arrays}
+		if err := e.Init(w); err != nil {
+			t.Fatal(err)
+		}
+		if err := e.WriteHeader("", hdr); err != nil {
+			t.Fatal(err)
+		}
+		for _, row := range table {
+			if err := e.WriteRow(row); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := e.Reset(); err != nil {
+			t.Fatal(err)
+		}
+		if err := e.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		out := buf.String()
+		if strings.Contains(out, `\u003c`) {
+			t.Fatalf("unexpected HTML escaping in %s", out)
+		}
+		h, rows := readAll(t, &Decoder{}, out, nil)
+		if h != strings.Join(hdr, ",") {
+			t.Fatalf("arrays=%v: expected header %q got %q", arrays, hdr, h)
+		}
+		cmp(t, out, [][]string{
+			{"1", "<nil>"},
+			{`"quoted" & <tagged>`, "π"},
+		}, rows)
+	}
+}
+
+func TestFrames(t *testing.T) {
+	var buf bytes.Buffer
+	e := &Encoder{}
+	if err := e.Init(fmttest.NewWriter(&buf)); err != nil {
+		t.Fatal(err)
+	}
+	for _, frame := range []string{"users", "orders"} {
+		if err := e.WriteHeader(frame, []string{"id"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := e.WriteRow([]value.Value{value.String(frame)}); err != nil {
+			t.Fatal(err)
+		}
+		if err := e.Reset(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := e.WriteHeader("users", []string{"id"}); err == nil {
+		t.Fatal("expected error writing duplicate frame")
+	}
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	d := &Decoder{}
+	if err := d.Init(fmttest.NewStringReader(out)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.ReadHeader("", nil); err != format.ErrFrameRequired {
+		t.Fatalf("expected %v got %v", format.ErrFrameRequired, err)
+	}
+	//the object is not lost by the failed read
+	if h, err := d.ReadHeader("users", nil); err != nil || len(h) != 1 || h[0] != "id" {
+		t.Fatalf("expected header id after retrying with a frame, got %q, %v", h, err)
+	}
+	if row, err := d.ReadRow(); err != nil || fmttest.Str(row[0]) != "users" {
+		t.Fatalf("expected row users after retrying with a frame, got %v", err)
+	}
+
+	for _, frame := range []string{"orders", "users"} {
+		d := &Decoder{}
+		if err := d.Init(fmttest.NewStringReader(out)); err != nil {
+			t.Fatal(err)
+		}
+		h, err := d.ReadHeader(frame, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(h) != 1 || h[0] != "id" {
+			t.Fatalf("%s: expected header id got %q", frame, h)
+		}
+		row, err := d.ReadRow()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmttest.Str(row[0]) != frame {
+			t.Fatalf("%s: got row %q from %s", frame, fmttest.Str(row[0]), out)
+		}
+		if _, err := d.ReadRow(); err != io.EOF {
+			t.Fatalf("%s: expected EOF got %v", frame, err)
+		}
+		if err := d.Reset(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := d.ReadHeader("missing", nil); err == nil {
+			t.Fatal("expected error on missing frame")
+		}
+	}
+}
+
+func TestTypes(t *testing.T) {
+	var buf bytes.Buffer
+	e := &Encoder{Arrays: true}
+	if err := e.Init(fmttest.NewWriter(&buf)); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.WriteHeader("", []string{"n", "i", "r", "inf", "t", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	row := []value.Value{
+		{},
+		{Kind: value.Integer, Text: "-7"},
+		{Kind: value.Real, Text: "1.0e+20"},
+		{Kind: value.Real, Text: "Inf"},
+		{Kind: value.Text, Text: "12"},
+		{Kind: value.Blob, Text: "\x00hi"},
+	}
+	if err := e.WriteRow(row); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	const exp = `[null,-7,1.0e+20,"Inf","12","AGhp"]`
+	if out := buf.String(); !strings.Contains(out, exp) {
+		t.Fatalf("expected %s in %s", exp, out)
+	}
+}
@@ -0,0 +1,246 @@
+package ndjsonfmt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/jimmyfrasche/etlite/internal/device"
+	"github.com/jimmyfrasche/etlite/internal/format"
+	"github.com/jimmyfrasche/etlite/internal/format/internal/jsonval"
+	"github.com/jimmyfrasche/etlite/internal/internal/errsys"
+)
+
+//DefaultSample is the number of lines used to derive
+//the header when Decoder.Sample is not positive.
+const DefaultSample = 100
+
+//Decoder is a newline-delimited JSON decoder.
+type Decoder struct {
+	Strict bool //When true reports an error if a row has more or less fields than the header
+	Sample int  //Number of lines used to derive the header, if not provided
+
+	r   *bufio.Reader
+	nm  string
+	lno int //last line read
+	at  int //line of the current row
+
+	hdr   []string
+	index map[string]int //column of each key in the header
+
+	pending []object //objects read to derive the header, in order
+	acc     []*string
+}
+
+type object struct {
+	line int
+	keys []string
+	vals []*string
+}
+
+func (d *Decoder) ctx() string {
+	return fmt.Sprintf("%s:%d:", d.nm, d.at)
+}
+
+var _ format.LineDecoder = (*Decoder)(nil)
+
+func (*Decoder) Name() string {
+	return "NDJSON"
+}
+
+func (d *Decoder) Init(r device.Reader) error {
+	d.nm = r.Name()
+	d.r = r.Unwrap()
+	d.lno, d.at = 0, 0
+	d.pending = d.pending[:0]
+	return nil
+}
+
+//ReadHeader derives the header from the union of the keys
+//of the first Sample objects, unless a header is provided.
+func (d *Decoder) ReadHeader(_ string, header []string) ([]string, error) {
+	if len(header) != 0 {
+		d.setHeader(header)
+		return d.hdr, nil
+	}
+
+	n := d.Sample
+	if n <= 0 {
+		n = DefaultSample
+	}
+	for len(d.pending) < n {
+		o, err := d.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		d.pending = append(d.pending, o)
+	}
+	if len(d.pending) == 0 {
+		return nil, format.ErrNoHeader
+	}
+
+	seen := map[string]bool{}
+	for _, o := range d.pending {
+		for _, k := range o.keys {
+			if !seen[k] {
+				seen[k] = true
+				header = append(header, k)
+			}
+		}
+	}
+	d.setHeader(header)
+	return d.hdr, nil
+}
+
+func (d *Decoder) setHeader(header []string) {
+	d.hdr = header
+	d.index = make(map[string]int, len(header))
+	for i, h := range header {
+		if _, ok := d.index[h]; !ok {
+			d.index[h] = i
+		}
+	}
+	if cap(d.acc) < len(header) {
+		d.acc = make([]*string, len(header))
+	}
+	d.acc = d.acc[:len(header)]
+}
+
+//Skip rows.
+func (d *Decoder) Skip(rows int) error {
+	for i := 0; i < rows; i++ {
+		if _, err := d.ReadRow(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+//ReadRow reads the next object.
+func (d *Decoder) ReadRow() ([]*string, error) {
+	var o object
+	if len(d.pending) > 0 {
+		o = d.pending[0]
+		d.pending[0] = object{}
+		d.pending = d.pending[1:]
+	} else {
+		var err error
+		o, err = d.next()
+		if err != nil {
+			return nil, err
+		}
+	}
+	d.at = o.line
+
+	for i := range d.acc {
+		d.acc[i] = nil
+	}
+	seen := 0
+	for i, k := range o.keys {
+		col, ok := d.index[k]
+		if !ok {
+			if d.Strict {
+				return nil, format.WrapRow(d.ctx(), fmt.Errorf("unexpected key %q", k))
+			}
+			continue
+		}
+		d.acc[col] = o.vals[i]
+		seen++
+	}
+	if d.Strict && seen != len(d.hdr) {
+		return nil, format.NewDimErr(d.ctx(), len(d.hdr), seen)
+	}
+	return d.acc, nil
+}
+
+//Line reports the line the last row read was on.
+func (d *Decoder) Line() int {
+	return d.at
+}
+
+//Reset the decoder for reuse.
+//
+//Any lines read to derive the header but not imported
+//are left for the next table on this device.
+func (d *Decoder) Reset() error {
+	return nil
+}
+
+//Close the decoder.
+func (d *Decoder) Close() error {
+	d.r = nil
+	d.hdr, d.index = nil, nil
+	for i := range d.pending {
+		d.pending[i] = object{}
+	}
+	d.pending = d.pending[:0]
+	for i := range d.acc {
+		d.acc[i] = nil
+	}
+	d.acc = d.acc[:0]
+	return nil
+}
+
+var errTrailing = errors.New("unexpected data after object")
+
+//next reads the object on the next nonblank line.
+func (d *Decoder) next() (object, error) {
+	for {
+		line, err := d.r.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return object{}, errsys.Wrap(err)
+		}
+		if len(line) == 0 && err == io.EOF {
+			return object{}, io.EOF
+		}
+		d.lno++
+		d.at = d.lno
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			if err == io.EOF {
+				return object{}, io.EOF
+			}
+			continue
+		}
+
+		o, perr := d.parse(line)
+		if perr != nil {
+			return object{}, format.WrapRow(d.ctx(), perr)
+		}
+		return o, nil
+	}
+}
+
+func (d *Decoder) parse(line []byte) (object, error) {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	t, err := dec.Token()
+	if err != nil {
+		return object{}, err
+	}
+	if t != json.Delim('{') {
+		return object{}, fmt.Errorf("expected object, got %v", t)
+	}
+	r := jsonval.Reader{Dec: dec}
+	keys, vals, err := r.Object()
+	if err != nil {
+		return object{}, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return object{}, errTrailing
+	}
+	return object{
+		line: d.lno,
+		keys: keys,
+		vals: vals,
+	}, nil
+}
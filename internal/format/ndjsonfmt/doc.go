@@ -0,0 +1,12 @@
+//Package ndjsonfmt defines encoding and decoding of
+//newline-delimited JSON, also known as JSON Lines.
+//
+//Each line holds a single JSON object, keyed by the column names in the header.
+//Blank lines are ignored.
+//
+//The decoder reads one line at a time,
+//so input of any size may be imported.
+//If no header is provided, it is the union of the keys
+//of the objects in the first lines of the input,
+//in the order they first appear.
+package ndjsonfmt
@@ -0,0 +1,78 @@
+package ndjsonfmt
+
+import (
+	"fmt"
+
+	"github.com/jimmyfrasche/etlite/internal/device"
+	"github.com/jimmyfrasche/etlite/internal/format"
+	"github.com/jimmyfrasche/etlite/internal/format/internal/jsonval"
+	"github.com/jimmyfrasche/etlite/internal/internal/errsys"
+	"github.com/jimmyfrasche/etlite/internal/internal/value"
+)
+
+//Encoder is a newline-delimited JSON encoder.
+type Encoder struct {
+	w    device.Writer
+	vals *jsonval.Writer
+	keys [][]byte //JSON encoded header
+	rows int
+}
+
+var _ format.Encoder = (*Encoder)(nil)
+
+func (e *Encoder) ctx() string {
+	return fmt.Sprintf("%s:%d:", e.w.Name(), e.rows)
+}
+
+func (*Encoder) Name() string {
+	return "NDJSON"
+}
+
+func (e *Encoder) Init(w device.Writer) error {
+	e.w = w
+	if e.vals == nil {
+		e.vals = jsonval.NewWriter()
+	}
+	return nil
+}
+
+//WriteHeader records the keys of the objects to write.
+//Nothing is written until the first row.
+func (e *Encoder) WriteHeader(_ string, hdr []string) error {
+	e.rows = 0
+	keys, err := e.vals.Keys(hdr)
+	if err != nil {
+		return format.Wrap(e.ctx(), err)
+	}
+	e.keys = keys
+	return nil
+}
+
+//WriteRow writes a row as an object on its own line.
+//NULL is encoded as null, INTEGER and REAL as numbers,
+//BLOB as a base64 string, and TEXT as a string.
+func (e *Encoder) WriteRow(row []value.Value) error {
+	e.rows++
+	b, err := e.vals.Object(e.keys, row)
+	if err != nil {
+		return format.Wrap(e.ctx(), err)
+	}
+	if _, err := e.w.Write(b); err != nil {
+		return errsys.WrapWith(e.ctx(), err)
+	}
+	if _, err := e.w.WriteString("\n"); err != nil {
+		return errsys.WrapWith(e.ctx(), err)
+	}
+	return nil
+}
+
+//Reset flushes the output.
+func (e *Encoder) Reset() error {
+	return e.w.Flush()
+}
+
+//Close the encoder.
+func (e *Encoder) Close() error {
+	e.w = nil
+	return nil
+}
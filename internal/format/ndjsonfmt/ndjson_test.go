@@ -0,0 +1,166 @@
+package ndjsonfmt
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/jimmyfrasche/etlite/internal/format/internal/fmttest"
+	"github.com/jimmyfrasche/etlite/internal/internal/value"
+)
+
+func readAll(t *testing.T, d *Decoder, in string, hdr []string) (string, [][]string) {
+	if err := d.Init(fmttest.NewStringReader(in)); err != nil {
+		t.Fatal(err)
+	}
+	h, err := d.ReadHeader("", hdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows [][]string
+	for {
+		row, err := d.ReadRow()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		var r []string
+		for _, v := range row {
+			r = append(r, fmttest.Str(v))
+		}
+		rows = append(rows, r)
+	}
+	if err := d.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	return strings.Join(h, ","), rows
+}
+
+func cmp(t *testing.T, ctx string, expected, got [][]string) {
+	if len(expected) != len(got) {
+		t.Fatalf("%s: expected %d rows got %d: %q", ctx, len(expected), len(got), got)
+	}
+	for i := range expected {
+		if e, g := strings.Join(expected[i], ","), strings.Join(got[i], ","); e != g {
+			t.Fatalf("%s: row %d expected %q got %q", ctx, i, e, g)
+		}
+	}
+}
+
+var decodeTests = []struct {
+	name, in string
+	sample   int
+	hdr      []string
+	expHdr   string
+	rows     [][]string
+}{
+	{
+		name:   "union",
+		in:     "{\"a\": 1, \"b\": \"x\"}\n\n{\"c\": true, \"a\": 2}\r\n{\"b\": [1, 2]}",
+		expHdr: "a,b,c",
+		rows: [][]string{
+			{"1", "x", "<nil>"},
+			{"2", "<nil>", "1"},
+			{"<nil>", "[1,2]", "<nil>"},
+		},
+	},
+	{
+		name:   "sample",
+		in:     "{\"a\": 1}\n{\"a\": 2, \"b\": 3}\n",
+		sample: 1,
+		expHdr: "a",
+		rows: [][]string{
+			{"1"},
+			{"2"},
+		},
+	},
+	{
+		name:   "header",
+		in:     "{\"a\": 1, \"b\": 2}\n",
+		hdr:    []string{"b", "z"},
+		expHdr: "b,z",
+		rows: [][]string{
+			{"2", "<nil>"},
+		},
+	},
+}
+
+func TestDecoder(t *testing.T) {
+	for _, test := range decodeTests {
+		hdr, rows := readAll(t, &Decoder{Sample: test.sample}, test.in, test.hdr)
+		if hdr != test.expHdr {
+			t.Fatalf("%s: expected header %q got %q", test.name, test.expHdr, hdr)
+		}
+		cmp(t, test.name, test.rows, rows)
+	}
+}
+
+func TestDecoderErrors(t *testing.T) {
+	for _, in := range []string{
+		"{\"a\": 1}\n{\"a\": 2} {\"a\": 3}\n",
+		"{\"a\": 1}\n[2]\n",
+		"{\"a\": 1}\n{\"a\": \n",
+	} {
+		d := &Decoder{Sample: 1}
+		if err := d.Init(fmttest.NewStringReader(in)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := d.ReadHeader("", nil); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := d.ReadRow(); err != nil {
+			t.Fatal(err)
+		}
+		_, err := d.ReadRow()
+		if err == nil || err == io.EOF {
+			t.Fatalf("%q: expected error got %v", in, err)
+		}
+		if !strings.HasPrefix(err.Error(), "test:2:") {
+			t.Fatalf("%q: expected error on line 2, got %v", in, err)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	hdr := []string{"a", "<b>"}
+	table := [][]value.Value{
+		{{Kind: value.Integer, Text: "1"}, {}},
+		{value.String("\"quoted\"\n& <tagged>"), value.String("π")},
+	}
+
+	var buf bytes.Buffer
+	e := &Encoder{}
+	if err := e.Init(fmttest.NewWriter(&buf)); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.WriteHeader("", hdr); err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range table {
+		if err := e.WriteRow(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := e.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	if n := strings.Count(out, "\n"); n != len(table) {
+		t.Fatalf("expected %d lines got %d: %s", len(table), n, out)
+	}
+	h, rows := readAll(t, &Decoder{}, out, nil)
+	if h != strings.Join(hdr, ",") {
+		t.Fatalf("expected header %q got %q", hdr, h)
+	}
+	cmp(t, out, [][]string{
+		{"1", "<nil>"},
+		{"\"quoted\"\n& <tagged>", "π"},
+	}, rows)
+}
@@ -20,11 +20,12 @@ type Decoder struct {
 	Strict   bool //When true reports an error if there are more or less fields than required
 	NoHeader bool //True if there is no header in the input
 
-	hdr []string //we stash this here if a header is provided and none in input
-	r   *bufio.Reader
-	err error
-	nm  string
-	lno int
+	hdr  []string //we stash this here if a header is provided and none in input
+	r    *bufio.Reader
+	err  error
+	nm   string
+	lno  int //line being read
+	line int //line the last row read began on
 
 	sacc []rune
 	facc []string
@@ -34,20 +35,20 @@ type Decoder struct {
 }
 
 func (d *Decoder) ctx() string {
-	return fmt.Sprintf("%s:%d:", d.nm, d.lno)
+	return fmt.Sprintf("%s:%d:", d.nm, d.line)
 }
 
 func (*Decoder) Name() string {
 	return "RAW"
 }
 
-var _ format.Decoder = (*Decoder)(nil)
+var _ format.LineDecoder = (*Decoder)(nil)
 
 func (d *Decoder) Init(r device.Reader) error {
 	d.nm = r.Name()
 	d.r = r.Unwrap()
 	d.resumed = false
-	d.lno = 1
+	d.lno, d.line = 1, 0
 	return nil
 }
 
@@ -129,6 +130,11 @@ func (d *Decoder) ReadRow() ([]*string, error) {
 	return d.racc, nil
 }
 
+//Line reports the line the last row read began on.
+func (d *Decoder) Line() int {
+	return d.line
+}
+
 //Reset the decoder for reuse
 func (d *Decoder) Reset() error {
 	d.hdr = nil
@@ -187,6 +193,7 @@ func (d *Decoder) readField() (f string, eol bool, err error) {
 }
 
 func (d *Decoder) read() ([]string, error) {
+	d.line = d.lno
 	if d.err != nil {
 		err := d.err
 		d.err = nil
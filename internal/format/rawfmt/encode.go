@@ -8,6 +8,7 @@ import (
 	"github.com/jimmyfrasche/etlite/internal/format"
 	"github.com/jimmyfrasche/etlite/internal/internal/errsys"
 	"github.com/jimmyfrasche/etlite/internal/internal/null"
+	"github.com/jimmyfrasche/etlite/internal/internal/value"
 )
 
 //Encoder encodes the raw format
@@ -80,9 +81,9 @@ func (e *Encoder) WriteHeader(_ string, hdr []string) error {
 }
 
 //WriteRow encodes a row
-func (e *Encoder) WriteRow(row []*string) error {
-	for i, s := range row {
-		if err := e.write(e.Null.Decode(s)); err != nil {
+func (e *Encoder) WriteRow(row []value.Value) error {
+	for i, v := range row {
+		if err := e.write(e.Null.DecodeValue(v)); err != nil {
 			return err
 		}
 		if i != len(row) {
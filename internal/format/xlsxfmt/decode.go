@@ -0,0 +1,258 @@
+package xlsxfmt
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/jimmyfrasche/etlite/internal/device"
+	"github.com/jimmyfrasche/etlite/internal/format"
+	"github.com/jimmyfrasche/etlite/internal/internal/errsys"
+)
+
+//Decoder is an XLSX decoder.
+type Decoder struct {
+	Strict   bool //When true reports an error if a row has more or less fields than the header
+	NoHeader bool //When true the first row of a sheet is data
+
+	r     device.Reader
+	nm    string
+	reset func() //returned by device.File, if used
+
+	book  *book
+	sheet string
+	rows  *rows
+
+	hdr []string
+	acc []*string
+
+	resumed bool
+}
+
+func (d *Decoder) ctx() string {
+	line := 0
+	if d.rows != nil {
+		line = d.rows.line
+	}
+	if d.sheet != "" {
+		return fmt.Sprintf("%s[%s]:%d:", d.nm, d.sheet, line)
+	}
+	return fmt.Sprintf("%s:%d:", d.nm, line)
+}
+
+var _ format.Decoder = (*Decoder)(nil)
+
+func (*Decoder) Name() string {
+	return "XLSX"
+}
+
+//Init the decoder.
+//The workbook is not read until the first call to ReadHeader.
+func (d *Decoder) Init(r device.Reader) error {
+	d.r = r
+	d.nm = r.Name()
+	d.book, d.sheet = nil, ""
+	d.resumed = false
+	return nil
+}
+
+//load the workbook, directly from the file if possible,
+//otherwise by reading the entire input into memory.
+func (d *Decoder) load() error {
+	if ok, err := d.loadFile(); ok || err != nil {
+		return err
+	}
+
+	p, err := ioutil.ReadAll(d.r.Unwrap())
+	if err != nil {
+		return errsys.Wrap(err)
+	}
+	b, err := openBook(bytes.NewReader(p), int64(len(p)))
+	if err != nil {
+		return format.Wrap(d.ctx(), err)
+	}
+	d.book = b
+	return nil
+}
+
+//loadFile loads the workbook directly from the file,
+//reporting false if the input is not a regular file.
+func (d *Decoder) loadFile() (bool, error) {
+	f, ok := d.r.(device.File)
+	if !ok {
+		return false, nil
+	}
+	fh, reset, err := f.File()
+	if err == device.ErrNotFile {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	d.reset = reset
+	st, err := fh.Stat()
+	if err != nil {
+		return false, errsys.Wrap(err)
+	}
+	if !st.Mode().IsRegular() {
+		return false, nil
+	}
+	b, err := openBook(fh, st.Size())
+	if err != nil {
+		return false, format.Wrap(d.ctx(), err)
+	}
+	d.book = b
+	return true, nil
+}
+
+//ReadHeader opens the sheet named by frame, or the only sheet
+//if no frame is provided, and returns its first row.
+//
+//If the previous table stopped before the end of its sheet,
+//and the frame has not changed, the sheet is continued with the same header.
+func (d *Decoder) ReadHeader(frame string, header []string) ([]string, error) {
+	if d.resumed && d.rows != nil && (frame == "" || frame == d.sheet) {
+		if len(header) != 0 {
+			d.setHeader(header)
+		}
+		return d.hdr, nil
+	}
+	if d.NoHeader && len(header) == 0 {
+		return nil, format.ErrNoHeader
+	}
+
+	if d.book == nil {
+		if err := d.load(); err != nil {
+			return nil, err
+		}
+	}
+	if err := d.closeRows(); err != nil {
+		return nil, err
+	}
+	s, err := d.book.sheet(frame)
+	if err != nil {
+		if err == format.ErrFrameRequired {
+			return nil, err
+		}
+		return nil, format.Wrap(d.ctx(), err)
+	}
+	d.sheet = s.name
+	d.rows, err = d.book.open(s)
+	if err != nil {
+		return nil, format.Wrap(d.ctx(), err)
+	}
+
+	if d.NoHeader {
+		d.setHeader(header)
+		return d.hdr, nil
+	}
+
+	row, err := d.rows.next()
+	if err == io.EOF {
+		if len(header) != 0 {
+			d.setHeader(header)
+			return d.hdr, nil
+		}
+		return nil, format.ErrNoHeader
+	}
+	if err != nil {
+		return nil, format.Wrap(d.ctx(), err)
+	}
+	if d.Strict && len(header) != 0 && len(header) != len(row) {
+		return nil, format.NewDimErr(d.ctx(), len(header), len(row))
+	}
+	if len(header) == 0 {
+		//empty cells in the header are empty names
+		header = make([]string, len(row))
+		for i, v := range row {
+			if v != nil {
+				header[i] = *v
+			}
+		}
+	}
+	d.setHeader(header)
+	return d.hdr, nil
+}
+
+func (d *Decoder) setHeader(header []string) {
+	d.hdr = header
+	if cap(d.acc) < len(header) {
+		d.acc = make([]*string, len(header))
+	}
+	d.acc = d.acc[:len(header)]
+}
+
+//Skip rows.
+func (d *Decoder) Skip(rows int) error {
+	for i := 0; i < rows; i++ {
+		if _, err := d.ReadRow(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+//ReadRow reads the next nonempty row of the sheet.
+func (d *Decoder) ReadRow() ([]*string, error) {
+	if d.rows == nil {
+		return nil, io.EOF
+	}
+	row, err := d.rows.next()
+	if err == io.EOF {
+		return nil, err
+	}
+	if err != nil {
+		return nil, format.Wrap(d.ctx(), err)
+	}
+	if d.Strict && len(row) != len(d.hdr) {
+		return nil, format.NewDimErr(d.ctx(), len(d.hdr), len(row))
+	}
+	if len(row) > len(d.hdr) {
+		//not in strict mode, ignore extra fields
+		row = row[:len(d.hdr)]
+	}
+	n := copy(d.acc, row)
+	for i := n; i < len(d.acc); i++ {
+		//not in strict mode, need to add in additional nulls
+		d.acc[i] = nil
+	}
+	return d.acc, nil
+}
+
+//Reset the decoder for reuse.
+//
+//The remainder of the current sheet, if any,
+//is left for the next table on this device.
+func (d *Decoder) Reset() error {
+	d.resumed = true
+	return nil
+}
+
+//Close the decoder.
+func (d *Decoder) Close() error {
+	err := d.closeRows()
+	if d.reset != nil {
+		d.reset()
+		d.reset = nil
+	}
+	d.r, d.book = nil, nil
+	d.hdr = nil
+	for i := range d.acc {
+		d.acc[i] = nil
+	}
+	d.acc = d.acc[:0]
+	return err
+}
+
+func (d *Decoder) closeRows() error {
+	if d.rows == nil {
+		return nil
+	}
+	err := d.rows.close()
+	d.rows = nil
+	return errsys.Wrap(err)
+}
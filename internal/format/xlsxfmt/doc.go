@@ -0,0 +1,18 @@
+//Package xlsxfmt defines encoding and decoding of Excel workbooks,
+//in the Office Open XML (.xlsx) format.
+//
+//Each frame is a worksheet named by the frame.
+//The first row of a sheet is the header.
+//
+//The decoder reads the sheet named by the frame,
+//or the only sheet if the workbook has one sheet.
+//Empty cells and cells holding errors are NULL, booleans are 1 and 0,
+//and numbers, including dates, are as stored in the workbook.
+//
+//The encoder collects each table as a sheet, named by the frame,
+//and writes the workbook when it is closed.
+//Tables without a frame are named Sheet1, Sheet2, and so on.
+//NULL is written as an empty cell.
+//Values that are plain decimal numbers are written as numbers,
+//everything else as text.
+package xlsxfmt
@@ -0,0 +1,214 @@
+package xlsxfmt
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/jimmyfrasche/etlite/internal/device"
+	"github.com/jimmyfrasche/etlite/internal/format"
+	"github.com/jimmyfrasche/etlite/internal/internal/errsys"
+	"github.com/jimmyfrasche/etlite/internal/internal/value"
+)
+
+//Encoder is an XLSX encoder.
+//
+//Nothing is written until the encoder is closed.
+type Encoder struct {
+	w      device.Writer
+	sheets []*sheet
+	names  map[string]bool //lowercased sheet names
+	cur    *sheet
+	rows   int
+}
+
+type sheet struct {
+	name string
+	buf  bytes.Buffer //the sheetData
+}
+
+var _ format.Encoder = (*Encoder)(nil)
+
+func (e *Encoder) ctx() string {
+	if e.cur == nil {
+		return e.w.Name() + ":"
+	}
+	return fmt.Sprintf("%s[%s]:%d:", e.w.Name(), e.cur.name, e.rows)
+}
+
+func (*Encoder) Name() string {
+	return "XLSX"
+}
+
+func (e *Encoder) Init(w device.Writer) error {
+	e.w = w
+	e.sheets, e.names, e.cur = nil, map[string]bool{}, nil
+	return nil
+}
+
+//WriteHeader starts a new sheet named frame and writes hdr as its first row.
+func (e *Encoder) WriteHeader(frame string, hdr []string) error {
+	if frame == "" {
+		frame = "Sheet" + strconv.Itoa(len(e.sheets)+1)
+	}
+	if err := validSheetName(frame); err != nil {
+		return format.Wrap(e.ctx(), err)
+	}
+	//Excel treats sheet names case insensitively
+	k := strings.ToLower(frame)
+	if e.names[k] {
+		return format.Wrap(e.ctx(), fmt.Errorf("sheet %q already written", frame))
+	}
+	e.names[k] = true
+
+	e.cur = &sheet{name: frame}
+	e.sheets = append(e.sheets, e.cur)
+	e.rows = 0
+
+	row := make([]value.Value, len(hdr))
+	for i, h := range hdr {
+		row[i] = value.String(h)
+	}
+	return e.WriteRow(row)
+}
+
+//WriteRow appends a row to the current sheet.
+//
+//INTEGER and REAL are written as numbers, BLOB as base64 text,
+//and TEXT as text. NULL cells are omitted.
+func (e *Encoder) WriteRow(row []value.Value) error {
+	if e.cur == nil {
+		return format.Wrap(e.ctx(), fmt.Errorf("row written before header"))
+	}
+	e.rows++
+	b := &e.cur.buf
+	r := strconv.Itoa(e.rows)
+	fmt.Fprintf(b, `<row r="%s">`, r)
+	for i, v := range row {
+		if v.IsNull() {
+			continue
+		}
+		ref := column(i) + r
+		if finite(v) {
+			fmt.Fprintf(b, `<c r="%s"><v>%s</v></c>`, ref, v.Text)
+			continue
+		}
+		s := v.Text
+		if v.Kind == value.Blob {
+			s = base64.StdEncoding.EncodeToString([]byte(s))
+		}
+		fmt.Fprintf(b, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref)
+		if err := xml.EscapeText(b, []byte(s)); err != nil {
+			return format.Wrap(e.ctx(), err)
+		}
+		b.WriteString(`</t></is></c>`)
+	}
+	b.WriteString("</row>")
+	return nil
+}
+
+//finite reports whether v is a number that Excel can represent.
+//SQLite renders infinite reals as Inf and -Inf.
+func finite(v value.Value) bool {
+	if !v.Numeric() {
+		return false
+	}
+	f, err := strconv.ParseFloat(v.Text, 64)
+	return err == nil && !math.IsInf(f, 0)
+}
+
+//Reset finishes the current sheet.
+func (e *Encoder) Reset() error {
+	e.cur = nil
+	return nil
+}
+
+//Close writes the workbook, if any sheets were written.
+func (e *Encoder) Close() error {
+	defer func() {
+		e.w, e.sheets, e.names, e.cur = nil, nil, nil, nil
+	}()
+	if len(e.sheets) == 0 {
+		return nil
+	}
+	z := zip.NewWriter(e.w)
+	if err := e.writeBook(z); err != nil {
+		return errsys.WrapWith(e.ctx(), err)
+	}
+	if err := z.Close(); err != nil {
+		return errsys.WrapWith(e.ctx(), err)
+	}
+	return errsys.WrapWith(e.ctx(), e.w.Flush())
+}
+
+const xmlHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+
+func (e *Encoder) writeBook(z *zip.Writer) error {
+	var types, wb, rels bytes.Buffer
+
+	types.WriteString(xmlHeader + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`)
+	wb.WriteString(xmlHeader + `<workbook xmlns="` + nsMain + `" xmlns:r="` + nsRel + `"><sheets>`)
+	rels.WriteString(xmlHeader + `<Relationships xmlns="` + nsPkg + `">`)
+
+	for i, s := range e.sheets {
+		n := strconv.Itoa(i + 1)
+		fmt.Fprintf(&types, `<Override PartName="/xl/worksheets/sheet%s.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, n)
+		wb.WriteString(`<sheet name="`)
+		if err := xml.EscapeText(&wb, []byte(s.name)); err != nil {
+			return err
+		}
+		fmt.Fprintf(&wb, `" sheetId="%s" r:id="rId%s"/>`, n, n)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%s" Type="%s" Target="worksheets/sheet%s.xml"/>`, n, relWorksheet, n)
+	}
+
+	types.WriteString(`</Types>`)
+	wb.WriteString(`</sheets></workbook>`)
+	rels.WriteString(`</Relationships>`)
+
+	files := []struct {
+		name string
+		r    io.Reader
+	}{
+		{"[Content_Types].xml", &types},
+		{"_rels/.rels", strings.NewReader(xmlHeader + `<Relationships xmlns="` + nsPkg + `">` +
+			`<Relationship Id="rId1" Type="` + relDocument + `" Target="xl/workbook.xml"/>` +
+			`</Relationships>`)},
+		{"xl/workbook.xml", &wb},
+		{"xl/_rels/workbook.xml.rels", &rels},
+	}
+	for _, f := range files {
+		if err := zipFile(z, f.name, f.r); err != nil {
+			return err
+		}
+	}
+
+	for i, s := range e.sheets {
+		r := io.MultiReader(
+			strings.NewReader(xmlHeader+`<worksheet xmlns="`+nsMain+`"><sheetData>`),
+			&s.buf,
+			strings.NewReader(`</sheetData></worksheet>`),
+		)
+		if err := zipFile(z, fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func zipFile(z *zip.Writer, name string, r io.Reader) error {
+	w, err := z.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
@@ -0,0 +1,351 @@
+package xlsxfmt
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/jimmyfrasche/etlite/internal/format"
+)
+
+const (
+	nsMain = "http://schemas.openxmlformats.org/spreadsheetml/2006/main"
+	nsRel  = "http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+	nsPkg  = "http://schemas.openxmlformats.org/package/2006/relationships"
+
+	relDocument      = nsRel + "/officeDocument"
+	relWorksheet     = nsRel + "/worksheet"
+	relSharedStrings = nsRel + "/sharedStrings"
+)
+
+//maxSheetName is the longest sheet name Excel accepts.
+const maxSheetName = 31
+
+//maxColumns is the number of columns Excel supports, A through XFD.
+const maxColumns = 16384
+
+//validSheetName reports why nm cannot name a worksheet, if it cannot.
+func validSheetName(nm string) error {
+	switch {
+	case nm == "":
+		return errors.New("sheet name cannot be empty")
+	case len([]rune(nm)) > maxSheetName:
+		return fmt.Errorf("sheet name %q longer than %d characters", nm, maxSheetName)
+	case strings.ContainsAny(nm, `[]:*?/\`):
+		return fmt.Errorf(`sheet name %q cannot contain any of []:*?/\`, nm)
+	case nm[0] == '\'' || nm[len(nm)-1] == '\'':
+		return fmt.Errorf("sheet name %q cannot begin or end with '", nm)
+	}
+	return nil
+}
+
+//column returns the letters naming the 0-based column i.
+func column(i int) string {
+	var b [8]byte
+	n := len(b)
+	for i++; i > 0; i = (i - 1) / 26 {
+		n--
+		b[n] = byte('A' + (i-1)%26)
+	}
+	return string(b[n:])
+}
+
+//columnOf returns the 0-based column of a cell reference like AB12.
+func columnOf(ref string) (int, error) {
+	col := 0
+	i := 0
+	for ; i < len(ref) && 'A' <= ref[i] && ref[i] <= 'Z'; i++ {
+		col = 26*col + int(ref[i]-'A') + 1
+		if col > maxColumns {
+			return 0, fmt.Errorf("cell reference %q beyond last column %s", ref, column(maxColumns-1))
+		}
+	}
+	if i == 0 {
+		return 0, fmt.Errorf("invalid cell reference %q", ref)
+	}
+	return col - 1, nil
+}
+
+type xmlRels struct {
+	Rels []struct {
+		ID     string `xml:"Id,attr"`
+		Type   string `xml:"Type,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xmlWorkbook struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+//xmlText is a rich or plain string, as in the shared strings table or an inline string.
+type xmlText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (x *xmlText) String() string {
+	if len(x.Runs) == 0 {
+		return x.T
+	}
+	s := x.T
+	for _, r := range x.Runs {
+		s += r.T
+	}
+	return s
+}
+
+type xmlSST struct {
+	SI []xmlText `xml:"si"`
+}
+
+type xmlCell struct {
+	R  string   `xml:"r,attr"`
+	T  string   `xml:"t,attr"`
+	V  *string  `xml:"v"`
+	Is *xmlText `xml:"is"`
+}
+
+type xmlRow struct {
+	R     int       `xml:"r,attr"`
+	Cells []xmlCell `xml:"c"`
+}
+
+type sheetRef struct {
+	name, path string
+}
+
+//book is a workbook opened for reading.
+type book struct {
+	files   map[string]*zip.File
+	sheets  []sheetRef
+	strings []string
+}
+
+func openBook(r io.ReaderAt, size int64) (*book, error) {
+	z, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	b := &book{
+		files: make(map[string]*zip.File, len(z.File)),
+	}
+	for _, f := range z.File {
+		b.files[f.Name] = f
+	}
+
+	var root xmlRels
+	if err := b.unmarshal("_rels/.rels", &root); err != nil {
+		return nil, err
+	}
+	wbPath := ""
+	for _, r := range root.Rels {
+		if r.Type == relDocument {
+			wbPath = resolve("", r.Target)
+		}
+	}
+	if wbPath == "" {
+		return nil, errors.New("not a workbook: no office document")
+	}
+
+	var wb xmlWorkbook
+	if err := b.unmarshal(wbPath, &wb); err != nil {
+		return nil, err
+	}
+	dir, file := path.Split(wbPath)
+	var rels xmlRels
+	if err := b.unmarshal(dir+"_rels/"+file+".rels", &rels); err != nil {
+		return nil, err
+	}
+	targets := map[string]string{}
+	for _, r := range rels.Rels {
+		p := resolve(dir, r.Target)
+		targets[r.ID] = p
+		if r.Type == relSharedStrings {
+			var sst xmlSST
+			if err := b.unmarshal(p, &sst); err != nil {
+				return nil, err
+			}
+			b.strings = make([]string, len(sst.SI))
+			for i := range sst.SI {
+				b.strings[i] = sst.SI[i].String()
+			}
+		}
+	}
+
+	for _, s := range wb.Sheets {
+		p, ok := targets[s.RID]
+		if !ok {
+			return nil, fmt.Errorf("no worksheet for sheet %q", s.Name)
+		}
+		b.sheets = append(b.sheets, sheetRef{s.Name, p})
+	}
+	return b, nil
+}
+
+//resolve a relationship target relative to dir.
+func resolve(dir, target string) string {
+	if strings.HasPrefix(target, "/") {
+		return target[1:]
+	}
+	return path.Clean(dir + target)
+}
+
+func (b *book) unmarshal(name string, v interface{}) error {
+	f, ok := b.files[name]
+	if !ok {
+		return fmt.Errorf("workbook missing %s", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return xml.NewDecoder(rc).Decode(v)
+}
+
+//sheet returns the sheet named nm, or the only sheet if nm is empty.
+func (b *book) sheet(nm string) (sheetRef, error) {
+	if nm == "" {
+		if len(b.sheets) != 1 {
+			return sheetRef{}, format.ErrFrameRequired
+		}
+		return b.sheets[0], nil
+	}
+	for _, s := range b.sheets {
+		if s.name == nm {
+			return s, nil
+		}
+	}
+	//Excel treats sheet names case insensitively
+	for _, s := range b.sheets {
+		if strings.EqualFold(s.name, nm) {
+			return s, nil
+		}
+	}
+	return sheetRef{}, fmt.Errorf("no sheet named %q", nm)
+}
+
+//rows reads the rows of a worksheet one at a time.
+type rows struct {
+	rc      io.ReadCloser
+	dec     *xml.Decoder
+	strings []string
+	line    int //row number of the last row read
+	acc     []*string
+}
+
+func (b *book) open(s sheetRef) (*rows, error) {
+	f, ok := b.files[s.path]
+	if !ok {
+		return nil, fmt.Errorf("workbook missing %s", s.path)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	return &rows{
+		rc:      rc,
+		dec:     xml.NewDecoder(rc),
+		strings: b.strings,
+	}, nil
+}
+
+//next returns the next nonempty row, or io.EOF.
+//The returned slice is only valid until the next call.
+func (r *rows) next() ([]*string, error) {
+	for {
+		t, err := r.dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := t.(type) {
+		case xml.EndElement:
+			if t.Name.Local == "sheetData" {
+				return nil, io.EOF
+			}
+		case xml.StartElement:
+			if t.Name.Local != "row" {
+				continue
+			}
+			var row xmlRow
+			if err := r.dec.DecodeElement(&row, &t); err != nil {
+				return nil, err
+			}
+			if row.R > 0 {
+				r.line = row.R
+			} else {
+				r.line++
+			}
+			if len(row.Cells) == 0 {
+				continue
+			}
+			return r.decode(row.Cells)
+		}
+	}
+}
+
+func (r *rows) decode(cells []xmlCell) ([]*string, error) {
+	for i := range r.acc {
+		r.acc[i] = nil
+	}
+	r.acc = r.acc[:0]
+	for i := range cells {
+		c := &cells[i]
+		col := len(r.acc)
+		if c.R != "" {
+			n, err := columnOf(c.R)
+			if err != nil {
+				return nil, err
+			}
+			col = n
+		}
+		for len(r.acc) <= col {
+			r.acc = append(r.acc, nil)
+		}
+		v, err := r.value(c)
+		if err != nil {
+			return nil, err
+		}
+		r.acc[col] = v
+	}
+	return r.acc, nil
+}
+
+func (r *rows) value(c *xmlCell) (*string, error) {
+	switch c.T {
+	case "inlineStr":
+		if c.Is == nil {
+			return nil, nil
+		}
+		s := c.Is.String()
+		return &s, nil
+	case "e":
+		return nil, nil
+	case "s":
+		if c.V == nil {
+			return nil, nil
+		}
+		i, err := strconv.Atoi(*c.V)
+		if err != nil || i < 0 || i >= len(r.strings) {
+			return nil, fmt.Errorf("invalid shared string %q in cell %s", *c.V, c.R)
+		}
+		s := r.strings[i]
+		return &s, nil
+	}
+	return c.V, nil
+}
+
+func (r *rows) close() error {
+	return r.rc.Close()
+}
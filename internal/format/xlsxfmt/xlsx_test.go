@@ -0,0 +1,256 @@
+package xlsxfmt
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/jimmyfrasche/etlite/internal/format"
+	"github.com/jimmyfrasche/etlite/internal/format/internal/fmttest"
+	"github.com/jimmyfrasche/etlite/internal/internal/value"
+)
+
+func s(v string) *string {
+	return &v
+}
+
+func v(k value.Kind, s string) value.Value {
+	return value.Value{Kind: k, Text: s}
+}
+
+func TestColumn(t *testing.T) {
+	for i, exp := range map[int]string{
+		0:     "A",
+		25:    "Z",
+		26:    "AA",
+		51:    "AZ",
+		52:    "BA",
+		701:   "ZZ",
+		702:   "AAA",
+		16383: "XFD",
+	} {
+		if got := column(i); got != exp {
+			t.Errorf("column(%d): expected %s got %s", i, exp, got)
+		}
+		if got, err := columnOf(exp + "12"); err != nil || got != i {
+			t.Errorf("columnOf(%s12): expected %d got %d, %v", exp, i, got, err)
+		}
+	}
+	for _, ref := range []string{"12", "XFE12", "AAAAAAAAAAAAAAA1"} {
+		if got, err := columnOf(ref); err == nil {
+			t.Errorf("columnOf(%s): expected error got %d", ref, got)
+		}
+	}
+}
+
+var sheets = []struct {
+	frame string
+	hdr   []string
+	rows  [][]value.Value
+	exp   [][]*string
+}{
+	{
+		frame: "users",
+		hdr:   []string{"id", "name"},
+		rows: [][]value.Value{
+			{v(value.Integer, "1"), v(value.Text, "<Ann & Bob>")},
+			{v(value.Integer, "2"), {}},
+			{v(value.Text, "007"), v(value.Blob, "\x00hi")},
+		},
+		exp: [][]*string{
+			{s("1"), s("<Ann & Bob>")},
+			{s("2"), nil},
+			{s("007"), s("AGhp")},
+		},
+	},
+	{
+		frame: "",
+		hdr:   []string{"x"},
+		rows: [][]value.Value{
+			{v(value.Real, "-1.5")},
+			{v(value.Real, "Inf")},
+		},
+		exp: [][]*string{
+			{s("-1.5")},
+			{s("Inf")},
+		},
+	},
+}
+
+func write(t *testing.T) []byte {
+	var buf bytes.Buffer
+	e := &Encoder{}
+	if err := e.Init(fmttest.NewWriter(&buf)); err != nil {
+		t.Fatal(err)
+	}
+	for _, sh := range sheets {
+		if err := e.WriteHeader(sh.frame, sh.hdr); err != nil {
+			t.Fatal(err)
+		}
+		for _, row := range sh.rows {
+			if err := e.WriteRow(row); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := e.Reset(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := e.WriteHeader("USERS", nil); err == nil {
+		t.Fatal("expected error writing duplicate sheet")
+	}
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	out := write(t)
+
+	d := &Decoder{}
+	if err := d.Init(fmttest.NewReader(bytes.NewReader(out))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.ReadHeader("", nil); err != format.ErrFrameRequired {
+		t.Fatalf("expected %v got %v", format.ErrFrameRequired, err)
+	}
+
+	for i := len(sheets) - 1; i >= 0; i-- {
+		sh := sheets[i]
+		frame := sh.frame
+		if frame == "" {
+			frame = "Sheet2"
+		}
+		hdr, err := d.ReadHeader(frame, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e, g := strings.Join(sh.hdr, ","), strings.Join(hdr, ","); e != g {
+			t.Fatalf("%s: expected header %q got %q", frame, e, g)
+		}
+		for j, exp := range sh.exp {
+			row, err := d.ReadRow()
+			if err != nil {
+				t.Fatalf("%s: row %d: %v", frame, j, err)
+			}
+			for k := range exp {
+				if e, g := fmttest.Str(exp[k]), fmttest.Str(row[k]); e != g {
+					t.Fatalf("%s: row %d col %d: expected %q got %q", frame, j, k, e, g)
+				}
+			}
+		}
+		if _, err := d.ReadRow(); err != io.EOF {
+			t.Fatalf("%s: expected EOF got %v", frame, err)
+		}
+		if err := d.Reset(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := d.ReadHeader("missing", nil); err == nil {
+		t.Fatal("expected error on missing sheet")
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	e := &Encoder{}
+	if err := e.Init(fmttest.NewWriter(&buf)); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("expected no output for no sheets, got %d bytes", buf.Len())
+	}
+}
+
+//TestSharedStrings reads a workbook laid out as spreadsheet programs write them.
+func TestSharedStrings(t *testing.T) {
+	files := map[string]string{
+		"_rels/.rels":     `<Relationships xmlns="` + nsPkg + `"><Relationship Id="rId1" Type="` + relDocument + `" Target="/xl/workbook.xml"/></Relationships>`,
+		"xl/workbook.xml": `<workbook xmlns="` + nsMain + `" xmlns:r="` + nsRel + `"><sheets><sheet name="Data" sheetId="7" r:id="rId3"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships xmlns="` + nsPkg + `">` +
+			`<Relationship Id="rId3" Type="` + relWorksheet + `" Target="worksheets/data.xml"/>` +
+			`<Relationship Id="rId4" Type="` + relSharedStrings + `" Target="sharedStrings.xml"/></Relationships>`,
+		"xl/sharedStrings.xml": `<sst xmlns="` + nsMain + `"><si><t>a</t></si><si><t>b</t></si><si><r><t>ri</t></r><r><t>ch</t></r></si></sst>`,
+		"xl/worksheets/data.xml": `<worksheet xmlns="` + nsMain + `"><sheetData>` +
+			`<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="C1" t="str"><v>c</v></c></row>` +
+			`<row r="3"><c r="A3" t="s"><v>2</v></c><c r="C3" t="b"><v>1</v></c></row>` +
+			`<row r="4"><c r="B4" t="e"><v>#DIV/0!</v></c><c r="C4"><v>4.25</v></c></row>` +
+			`</sheetData></worksheet>`,
+	}
+	var buf bytes.Buffer
+	z := zip.NewWriter(&buf)
+	for name, body := range files {
+		if err := zipFile(z, name, strings.NewReader(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	d := &Decoder{}
+	if err := d.Init(fmttest.NewReader(&buf)); err != nil {
+		t.Fatal(err)
+	}
+	hdr, err := d.ReadHeader("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := strings.Join(hdr, ","); h != "a,b,c" {
+		t.Fatalf("expected header a,b,c got %s", h)
+	}
+	for _, exp := range []string{"rich,<nil>,1", "<nil>,<nil>,4.25"} {
+		row, err := d.ReadRow()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, v := range row {
+			got = append(got, fmttest.Str(v))
+		}
+		if g := strings.Join(got, ","); g != exp {
+			t.Fatalf("expected %s got %s", exp, g)
+		}
+	}
+	if _, err := d.ReadRow(); err != io.EOF {
+		t.Fatalf("expected EOF got %v", err)
+	}
+}
+
+//TestNumbers checks that only INTEGER and REAL values are written as numbers.
+func TestNumbers(t *testing.T) {
+	out := write(t)
+	z, err := zip.NewReader(bytes.NewReader(out), int64(len(out)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sheet bytes.Buffer
+	for _, f := range z.File {
+		if f.Name != "xl/worksheets/sheet1.xml" {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := sheet.ReadFrom(r); err != nil {
+			t.Fatal(err)
+		}
+		r.Close()
+	}
+	for _, exp := range []string{`<c r="A2"><v>1</v></c>`, `<c r="A4" t="inlineStr">`} {
+		if !strings.Contains(sheet.String(), exp) {
+			t.Fatalf("expected %s in %s", exp, sheet.String())
+		}
+	}
+}
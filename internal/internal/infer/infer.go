@@ -0,0 +1,89 @@
+//Package infer chooses the SQLite type affinity of columns
+//from a sample of their values.
+package infer
+
+import (
+	"strconv"
+	"strings"
+)
+
+type affinity int
+
+//ordered from most to least specific
+const (
+	affNone affinity = iota
+	affInteger
+	affReal
+	affText
+)
+
+func (a affinity) String() string {
+	switch a {
+	case affInteger:
+		return "INTEGER"
+	case affReal:
+		return "REAL"
+	}
+	return "TEXT"
+}
+
+//Types accumulates the affinity of each column of a sample of rows.
+//
+//The zero value is ready to use.
+type Types struct {
+	cols []affinity
+}
+
+//Add a row to the sample.
+//
+//NULL and empty values do not affect the result.
+func (t *Types) Add(row []*string) {
+	for len(t.cols) < len(row) {
+		t.cols = append(t.cols, affNone)
+	}
+	for i, v := range row {
+		if v == nil || *v == "" || t.cols[i] == affText {
+			continue
+		}
+		if a := of(*v); a > t.cols[i] {
+			t.cols[i] = a
+		}
+	}
+}
+
+//Affinities returns INTEGER, REAL, or TEXT for each of the first n columns.
+//Columns with no values in the sample are TEXT.
+func (t *Types) Affinities(n int) []string {
+	out := make([]string, n)
+	for i := range out {
+		a := affNone
+		if i < len(t.cols) {
+			a = t.cols[i]
+		}
+		out[i] = a.String()
+	}
+	return out
+}
+
+func of(s string) affinity {
+	if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if leadingZero(s) {
+			//keep identifiers like zip codes intact
+			return affText
+		}
+		return affInteger
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		//reject spellings SQLite would not treat as a number
+		if strings.ContainsAny(s, "nNiIxX_") {
+			return affText
+		}
+		return affReal
+	}
+	return affText
+}
+
+func leadingZero(s string) bool {
+	s = strings.TrimLeft(s, "+-")
+	return len(s) > 1 && s[0] == '0'
+}
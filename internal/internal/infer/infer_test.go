@@ -0,0 +1,28 @@
+package infer
+
+import (
+	"strings"
+	"testing"
+)
+
+func row(vs ...string) []*string {
+	out := make([]*string, len(vs))
+	for i := range vs {
+		if vs[i] != "<nil>" {
+			out[i] = &vs[i]
+		}
+	}
+	return out
+}
+
+func TestTypes(t *testing.T) {
+	var ts Types
+	ts.Add(row("1", "1", "1", "<nil>", "007", "1"))
+	ts.Add(row("-20", "2.5", "x", "<nil>", "7", "Inf"))
+	ts.Add(row("", "1e3", "3", "<nil>", "8"))
+
+	exp := "INTEGER,REAL,TEXT,TEXT,TEXT,TEXT,TEXT"
+	if got := strings.Join(ts.Affinities(7), ","); got != exp {
+		t.Fatalf("expected %s got %s", exp, got)
+	}
+}
@@ -2,6 +2,8 @@
 //for formats that do not have a native definition equivalent to NULL.
 package null
 
+import "github.com/jimmyfrasche/etlite/internal/internal/value"
+
 //Encoding defines how SQL NULL is encoded in text.
 type Encoding string
 
@@ -20,3 +22,11 @@ func (n Encoding) Decode(s *string) string {
 	}
 	return *s
 }
+
+//DecodeValue returns n if v is NULL and the text of v otherwise.
+func (n Encoding) DecodeValue(v value.Value) string {
+	if v.IsNull() {
+		return string(n)
+	}
+	return v.Text
+}
@@ -37,6 +37,27 @@ func (s *Stack) Top() string {
 	return s.s[0]
 }
 
+//Copy returns an independent copy of s.
+func (s *Stack) Copy() *Stack {
+	return &Stack{
+		trans: s.trans,
+		s:     append([]string(nil), s.s...),
+	}
+}
+
+//Equal reports whether s and t have the same transaction and savepoints.
+func (s *Stack) Equal(t *Stack) bool {
+	if s.trans != t.trans || len(s.s) != len(t.s) {
+		return false
+	}
+	for i := range s.s {
+		if s.s[i] != t.s[i] {
+			return false
+		}
+	}
+	return true
+}
+
 //Begin a transaction.
 func (s *Stack) Begin() error {
 	if s.trans {
@@ -59,6 +80,41 @@ func (s *Stack) End() error {
 	return nil
 }
 
+//Rollback the transaction, or, if there is no transaction,
+//all the savepoints on the Stack.
+func (s *Stack) Rollback() error {
+	if !s.Open() {
+		return errors.New("no open transaction or savepoint to roll back")
+	}
+	s.trans = false
+	s.s = s.s[:0]
+	return nil
+}
+
+//RollbackTo a savepoint, removing the savepoints after it from the Stack.
+//The savepoint itself remains on the Stack.
+//It returns the Depth of the Stack before the savepoint.
+func (s *Stack) RollbackTo(sp string) (int, error) {
+	p := s.last(sp)
+	if p < 0 {
+		return 0, fmt.Errorf("attempting to roll back to unknown savepoint %s", sp)
+	}
+	s.s = s.s[:p+1]
+	if s.trans {
+		p++
+	}
+	return p, nil
+}
+
+//Depth is the number of open transactions and savepoints.
+func (s *Stack) Depth() int {
+	n := len(s.s)
+	if s.trans {
+		n++
+	}
+	return n
+}
+
 //Savepoint adds a new savepoint to the Stack.
 func (s *Stack) Savepoint(sp string) {
 	s.s = append(s.s, sp)
@@ -70,7 +126,7 @@ func (s *Stack) Release(sp string) error {
 	if p < 0 {
 		return fmt.Errorf("attempting to release unknown savepoint %s", sp)
 	}
-	s.s = s.s[:len(s.s)-p]
+	s.s = s.s[:p]
 	return nil
 }
 
@@ -41,7 +41,10 @@ func (b *builder) join() string {
 
 //CreateTable synthesizes a create (temporary) table statement
 //using the given header.
-func CreateTable(temporary bool, name string, header []string) string {
+//
+//If types is not nil, it holds the declared type of each column.
+//Columns without a type are TEXT.
+func CreateTable(temporary bool, name string, header, types []string) string {
 	b := build("CREATE")
 
 	if temporary {
@@ -50,8 +53,14 @@ func CreateTable(temporary bool, name string, header []string) string {
 
 	b.push("TABLE", name, "(")
 
+	col := 0
 	b.csv(header, func(h string) {
-		b.push(h, "TEXT")
+		typ := "TEXT"
+		if col < len(types) && types[col] != "" {
+			typ = types[col]
+		}
+		b.push(h, typ)
+		col++
 	})
 
 	b.push(");")
@@ -62,8 +71,15 @@ func CreateTable(temporary bool, name string, header []string) string {
 //Insert synthesizes an insert statement into the table name,
 //using the given header and with placeholders for each item
 //in the header.
-func Insert(name string, header []string) string {
-	b := build("INSERT INTO", name, "(")
+//
+//If conflict is not empty, it is the conflict resolution algorithm
+//of the insert, such as IGNORE or REPLACE.
+func Insert(conflict, name string, header []string) string {
+	b := build("INSERT")
+	if conflict != "" {
+		b.push("OR", conflict)
+	}
+	b.push("INTO", name, "(")
 
 	b.csv(header, func(h string) {
 		b.push(h)
@@ -0,0 +1,62 @@
+//Package value represents SQLite values along with their storage class.
+package value
+
+//Kind is the storage class of an SQLite value.
+type Kind int
+
+//The SQLite storage classes.
+const (
+	Null Kind = iota
+	Integer
+	Real
+	Text
+	Blob
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Null:
+		return "NULL"
+	case Integer:
+		return "INTEGER"
+	case Real:
+		return "REAL"
+	case Text:
+		return "TEXT"
+	case Blob:
+		return "BLOB"
+	}
+	return "UNKNOWN"
+}
+
+//Value is a single SQLite value.
+//
+//Text is the value as rendered by SQLite, except for blobs,
+//where it is the raw bytes, and NULL, where it is empty.
+type Value struct {
+	Kind Kind
+	Text string
+}
+
+//String returns a TEXT value of s.
+func String(s string) Value {
+	return Value{Kind: Text, Text: s}
+}
+
+//Of returns NULL if s is nil and a TEXT value of *s otherwise.
+func Of(s *string) Value {
+	if s == nil {
+		return Value{}
+	}
+	return String(*s)
+}
+
+//IsNull reports whether v is NULL.
+func (v Value) IsNull() bool {
+	return v.Kind == Null
+}
+
+//Numeric reports whether v is an INTEGER or REAL.
+func (v Value) Numeric() bool {
+	return v.Kind == Integer || v.Kind == Real
+}
@@ -11,6 +11,18 @@ import (
 	"github.com/jimmyfrasche/etlite/internal/token"
 )
 
+//UnterminatedError is the Err of an illegal token when the input ends
+//inside a string or comment.
+//
+//It allows the input to be completed by further input.
+type UnterminatedError struct {
+	What string
+}
+
+func (u *UnterminatedError) Error() string {
+	return "EOF in " + u.What
+}
+
 type lexer struct {
 	*stream
 	start      token.Position
@@ -38,6 +50,12 @@ func (l *lexer) error(s string) state {
 	return nil
 }
 
+//eofIn reports an unterminated string or comment.
+func (l *lexer) eofIn(what string) state {
+	l.emitErr(&UnterminatedError{What: what})
+	return nil
+}
+
 func (l *lexer) errorf(spec string, vs ...interface{}) state {
 	l.emitErr(fmt.Errorf(spec, vs...))
 	return nil
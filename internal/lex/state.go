@@ -39,7 +39,7 @@ func lineComment(l *lexer) state {
 func multilineComment(l *lexer) state {
 	l.ignoreUntil(is('*'))
 	if l.eof() {
-		return l.error("EOF in /* */ comment")
+		return l.eofIn("/* */ comment")
 	}
 
 	if !l.maybe('/') {
@@ -57,7 +57,8 @@ func multilineComment(l *lexer) state {
 func qstring(l *lexer) state {
 	l.until(l.stringType)
 	if l.eof() {
-		return l.errorf("EOF in %[1]cstring%[1]c", l.stringType)
+		q := string(l.stringType)
+		return l.eofIn(q + "string" + q)
 	}
 	l.consume()
 	if l.maybe(l.stringType) {
@@ -73,7 +74,7 @@ func qstring(l *lexer) state {
 func bstring(l *lexer) state {
 	l.until(']')
 	if l.eof() {
-		return l.error("EOF in [string]")
+		return l.eofIn("[string]")
 	}
 	l.consume()
 	l.emitString()
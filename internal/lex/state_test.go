@@ -82,7 +82,14 @@ func TestMultilineComment(t *testing.T) {
 		l = fakeLexer(in)
 		multilineComment(l)
 		tk := <-l.tokens
-		errtk(t, tk, "EOF in /* */ comment")
+		unterminated(t, tk, "EOF in /* */ comment")
+	}
+}
+
+func unterminated(t *testing.T, tk token.Value, emsg string) {
+	errtk(t, tk, emsg)
+	if _, ok := tk.Err.(*UnterminatedError); !ok {
+		t.Fatalf("Expected *UnterminatedError got %T", tk.Err)
 	}
 }
 
@@ -114,7 +121,7 @@ func TestQString(t *testing.T) {
 	l.stringType = '"'
 	l.consume()
 	qstring(l)
-	errtk(t, <-l.tokens, `EOF in "string"`)
+	unterminated(t, <-l.tokens, `EOF in "string"`)
 }
 
 func TestBString(t *testing.T) {
@@ -131,7 +138,7 @@ func TestBString(t *testing.T) {
 	l = fakeLexer(invalid)
 	l.consume() //record [
 	bstring(l)
-	errtk(t, <-l.tokens, "EOF in [string]")
+	unterminated(t, <-l.tokens, "EOF in [string]")
 }
 
 func TestBlob(t *testing.T) {
@@ -2,6 +2,7 @@ package parse
 
 import (
 	"strconv"
+	"time"
 
 	"github.com/jimmyfrasche/etlite/internal/ast"
 	"github.com/jimmyfrasche/etlite/internal/internal/null"
@@ -9,10 +10,27 @@ import (
 	"github.com/jimmyfrasche/etlite/internal/token"
 )
 
-//TO|FROM STDIN|STDOUT|filename
+//TO|FROM STDIN|STDOUT|[FILE] filename [COMPRESSED compression]
+//FROM FILES pattern [COMPRESSED compression] [SOURCE]
+//TO|FROM COMMAND command
 func (p *parser) deviceExpr(toFrom token.Value) (ast.Device, token.Value) {
 	t := p.next()
 
+	if t.Literal("COMMAND") {
+		t = p.expectLitOrStr()
+		if _, ok := t.Unescape(); !ok {
+			panic(p.unexpected(t))
+		}
+		return &ast.DeviceCommand{Command: t}, p.next()
+	}
+
+	if t.Literal("FILES") {
+		if toFrom.Literal("TO") {
+			panic(p.errMsg(t, "FILES can only be read from"))
+		}
+		return p.filesExpr()
+	}
+
 	if toFrom.Literal("TO") {
 		if t.Literal("STDIN") {
 			panic(p.errMsg(t, "expected STDIN or filename, got STDOUT"))
@@ -28,15 +46,86 @@ func (p *parser) deviceExpr(toFrom token.Value) (ast.Device, token.Value) {
 	}
 
 	//here t cannot be STDIN or STDOUT, so it must be a filename
-	_, ok := t.Unescape()
-	if !ok {
-		panic(p.unexpected(t))
+	if t.Literal("FILE") {
+		t = p.next()
 	}
-
 	d := &ast.DeviceFile{
 		Name: t,
 	}
-	return d, p.next()
+	d.NameExpr, t = p.nameExpr(t)
+	if t.Literal("COMPRESSED") {
+		d.Compression, t = p.compression(p.expect(token.Literal))
+	}
+	return d, t
+}
+
+//nameExpr parses a filename or
+//a concatenation of strings and @ arguments, such as @dir || '/out.csv'.
+//If t is a single filename, the returned SQL is nil.
+func (p *parser) nameExpr(t token.Value) (*ast.SQL, token.Value) {
+	part := func(t token.Value) bool {
+		return t.Kind == token.Argument || t.Kind == token.String && t.StringKind == '\''
+	}
+	if _, ok := t.Unescape(); !ok && t.Kind != token.Argument {
+		panic(p.unexpected(t))
+	}
+	first := t
+	s := &ast.SQL{
+		Kind:   ast.Query,
+		Tokens: []token.Value{t},
+	}
+	for t = p.next(); t.Literal("||"); t = p.next() {
+		if !part(first) {
+			panic(p.expected("string or @ argument", first))
+		}
+		s.Tokens = append(s.Tokens, t)
+		if t = p.next(); !part(t) {
+			panic(p.expected("string or @ argument", t))
+		}
+		s.Tokens = append(s.Tokens, t)
+	}
+	if first.Kind != token.Argument && len(s.Tokens) == 1 {
+		return nil, t
+	}
+	return s, t
+}
+
+func (p *parser) filesExpr() (ast.Device, token.Value) {
+	t := p.expectLitOrStr()
+	if _, ok := t.Unescape(); !ok {
+		panic(p.unexpected(t))
+	}
+	d := &ast.DeviceFiles{
+		Pattern: t,
+	}
+	t = p.next()
+	if t.Literal("COMPRESSED") {
+		d.Compression, t = p.compression(p.expect(token.Literal))
+	}
+	if t.Literal("SOURCE") {
+		d.Source = true
+		t = p.next()
+	}
+	return d, t
+}
+
+func (p *parser) compression(t token.Value) (ast.Compression, token.Value) {
+	switch t.Canon {
+	case "DETECT":
+		return ast.DetectCompression, p.next()
+	case "NONE":
+		return ast.NoCompression, p.next()
+	case "GZIP", "GZ":
+		return ast.Gzip, p.next()
+	case "BZIP2", "BZ2":
+		return ast.Bzip2, p.next()
+	case "XZ":
+		return ast.XZ, p.next()
+	case "ZSTD":
+		return ast.Zstd, p.next()
+	default:
+		panic(p.expected("a compression (DETECT, NONE, GZIP, BZIP2, XZ, ZSTD)", t))
+	}
 }
 
 func (p *parser) frameExpr(t token.Value) (string, token.Value) {
@@ -54,6 +143,10 @@ func (p *parser) formatExpr(t token.Value) (ast.Format, token.Value) {
 	switch t.Canon {
 	case "JSON":
 		return p.formatJSON(t)
+	case "NDJSON", "JSONL":
+		return p.formatNDJSON(t)
+	case "XLSX":
+		return p.formatXLSX(t)
 	case "RAW":
 		return p.formatRaw(t)
 	case "CSV":
@@ -64,9 +157,54 @@ func (p *parser) formatExpr(t token.Value) (ast.Format, token.Value) {
 }
 
 func (p *parser) formatJSON(t token.Value) (ast.Format, token.Value) {
-	return &ast.FormatJSON{
+	f := &ast.FormatJSON{
 		Position: t.Position,
-	}, p.next()
+	}
+	t = p.next()
+	if t.Literal("STRICT") {
+		f.Strict = true
+		t = p.next()
+	}
+	if t.AnyLiteral("ARRAYS", "ARRAY") {
+		f.Arrays = true
+		t = p.next()
+	}
+	return f, t
+}
+
+func (p *parser) formatNDJSON(t token.Value) (ast.Format, token.Value) {
+	f := &ast.FormatNDJSON{
+		Position: t.Position,
+	}
+	t = p.next()
+	if t.Literal("STRICT") {
+		f.Strict = true
+		t = p.next()
+	}
+	if t.Literal("SAMPLE") {
+		n := p.next()
+		f.Sample, t = p.int(n)
+		if f.Sample <= 0 {
+			panic(p.expected("a positive number of lines to sample", n))
+		}
+	}
+	return f, t
+}
+
+func (p *parser) formatXLSX(t token.Value) (ast.Format, token.Value) {
+	f := &ast.FormatXLSX{
+		Position: t.Position,
+	}
+	t = p.next()
+	if t.Literal("STRICT") {
+		f.Strict = true
+		t = p.next()
+	}
+	if t.AnyLiteral("NOHEADER", "NOHDR") {
+		f.NoHeader = true
+		t = p.next()
+	}
+	return f, t
 }
 
 func (p *parser) formatRaw(t token.Value) (ast.Format, token.Value) {
@@ -94,6 +232,8 @@ func (p *parser) formatCSV(t token.Value) (ast.Format, token.Value) {
 		Position: t.Position,
 		Delim:    -1,
 		Quote:    -1,
+		Escape:   -1,
+		Comment:  -1,
 	}
 	t = p.next()
 	if t.Literal("STRICT") {
@@ -102,6 +242,16 @@ func (p *parser) formatCSV(t token.Value) (ast.Format, token.Value) {
 	}
 	f.Delim, t = p.delim(t)
 	f.Quote, t = p.quote(t)
+	if t.Literal("ESCAPE") {
+		f.Escape, t = p.rune(p.next())
+	}
+	if t.Literal("COMMENT") {
+		f.Comment, t = p.rune(p.next())
+	}
+	if t.Literal("TRIM") {
+		f.Trim = true
+		t = p.next()
+	}
 	f.Line, t = p.lineEnding(t)
 	f.Null, t = p.null(t)
 	if t.AnyLiteral("NOHEADER", "NOHDR") {
@@ -154,14 +304,45 @@ func (p *parser) int(t token.Value) (int, token.Value) {
 	if t.Kind != token.Literal {
 		panic(p.unexpected(t))
 	}
-	if t.Literal("-") {
+	neg := t.Literal("-")
+	if neg {
 		t = p.expect(token.Literal)
 	}
 	i, err := strconv.Atoi(t.Value)
 	if err != nil {
 		panic(p.mkErr(t, err))
 	}
-	return i, t
+	if neg {
+		i = -i
+	}
+	return i, p.next()
+}
+
+//duration is NONE, a number of seconds, or a string such as '1h30m'.
+func (p *parser) duration(t token.Value) (time.Duration, token.Value) {
+	var d time.Duration
+	switch {
+	case t.Literal("NONE"):
+	case t.Kind == token.String:
+		s, _ := t.Unescape()
+		var err error
+		d, err = time.ParseDuration(s)
+		if err != nil {
+			panic(p.mkErr(t, err))
+		}
+	case t.Kind == token.Literal:
+		f, err := strconv.ParseFloat(t.Value, 64)
+		if err != nil {
+			panic(p.expected("duration", t))
+		}
+		d = time.Duration(f * float64(time.Second))
+	default:
+		panic(p.expected("duration", t))
+	}
+	if d < 0 {
+		panic(p.expected("non-negative duration", t))
+	}
+	return d, p.next()
 }
 
 func (p *parser) quote(t token.Value) (rune, token.Value) {
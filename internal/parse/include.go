@@ -0,0 +1,81 @@
+package parse
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/jimmyfrasche/etlite/internal/ast"
+	"github.com/jimmyfrasche/etlite/internal/internal/errusr"
+	"github.com/jimmyfrasche/etlite/internal/lex"
+	"github.com/jimmyfrasche/etlite/internal/token"
+)
+
+//INCLUDE 'file'
+//
+//The statements in file are parsed in place and passed to emit.
+//A relative file is found relative to the directory of the script including it.
+func (p *parser) include(t token.Value, emit func(ast.Node)) {
+	t = p.expectLitOrStr()
+	name, ok := t.Unescape()
+	if !ok || name == "" {
+		panic(p.unexpected(t))
+	}
+	p.expect(token.Semicolon)
+
+	if !filepath.IsAbs(name) && isFile(t.Name) {
+		name = filepath.Join(filepath.Dir(t.Name), name)
+	}
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		panic(p.mkErr(t, errusr.Wrap(t.Position, err)))
+	}
+
+	files := p.files
+	if len(files) == 0 && isFile(t.Name) {
+		//the outermost script
+		if f, err := filepath.Abs(t.Name); err == nil {
+			files = []string{f}
+		}
+	}
+	for _, f := range files {
+		if f == abs {
+			chain := append(files[:len(files):len(files)], abs)
+			panic(p.errMsg(t, "INCLUDE cycle: %s", strings.Join(chain, " -> ")))
+		}
+	}
+
+	src, err := ioutil.ReadFile(name)
+	if err != nil {
+		panic(p.mkErr(t, errusr.Wrap(t.Position, err)))
+	}
+
+	sub := &parser{
+		in:    lex.Stream(name, bytes.NewReader(src)),
+		out:   p.out,
+		files: append(files[:len(files):len(files)], abs),
+	}
+	defer func() {
+		x := recover()
+		//if parsing stopped early, the lexer is still sending the rest of the file
+		for range sub.in {
+		}
+		//an incomplete statement in an included file is an error,
+		//not a prompt for more input.
+		if x != nil {
+			if e, ok := x.(*ast.Error); ok && e.Err == io.ErrUnexpectedEOF {
+				panic(sub.errMsg(e.Token, "unexpected EOF"))
+			}
+			panic(x)
+		}
+	}()
+	sub.statements(emit)
+}
+
+//isFile reports whether name, the name of a script, is a file,
+//rather than a name like <STDIN>.
+func isFile(name string) bool {
+	return name != "" && !strings.HasPrefix(name, "<")
+}
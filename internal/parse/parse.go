@@ -19,6 +19,10 @@ type parser struct {
 	in   <-chan token.Value
 	out  chan<- ast.Node
 	last token.Value
+
+	//absolute paths of the files being parsed, outermost first,
+	//for detecting INCLUDE cycles
+	files []string
 }
 
 func newParser(tokens <-chan token.Value, nodes chan<- ast.Node) *parser {
@@ -43,13 +47,25 @@ func (p *parser) parse() {
 		close(p.out)
 	}()
 
+	p.statements(func(n ast.Node) {
+		p.out <- n
+	})
+}
+
+//statements parses statements until the input is exhausted,
+//passing each to emit.
+func (p *parser) statements(emit func(ast.Node)) {
 	for {
 		t, ok := <-p.in
 		if !ok {
 			return
 		}
 		p.last = t
-		p.out <- p.parseETL(t)
+		if t.Literal("INCLUDE") {
+			p.include(t, emit)
+			continue
+		}
+		emit(p.parseETL(t))
 	}
 }
 
@@ -0,0 +1,89 @@
+package parse
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jimmyfrasche/etlite/internal/ast"
+	"github.com/jimmyfrasche/etlite/internal/lex"
+)
+
+//nodes parses the script src and returns its nodes, stopping at the first error.
+func nodes(src string) ([]ast.Node, error) {
+	var ns []ast.Node
+	for n := range Tokens(lex.Stream("test", strings.NewReader(src))) {
+		if err, ok := n.(*ast.Error); ok {
+			return ns, err.Err
+		}
+		ns = append(ns, n)
+	}
+	return ns, nil
+}
+
+//TestBlockEnd tests that blocks end with END and their keyword
+//and that END cannot be used for COMMIT inside them.
+func TestBlockEnd(t *testing.T) {
+	for _, src := range []string{
+		"IF (SELECT 1) THEN SAVEPOINT a; SELECT 1; RELEASE a; END IF;",
+		"IF (SELECT 1) THEN SELECT 1; ELSE SELECT 2; END IF;",
+		"FOR EACH ROW IN (SELECT 1 AS x) DO SELECT @x; COMMIT TRANSACTION; END FOR;",
+		"TRY SELECT 1; CATCH SELECT 2; END TRY;",
+		"TRY FOR EACH ROW IN (SELECT 1 AS x) DO IF (SELECT 1) THEN SELECT 1; END IF; END FOR; CATCH SELECT 2; END TRY;",
+	} {
+		ns, err := nodes(src)
+		if err != nil {
+			t.Errorf("%s: got error: %s", src, err)
+			continue
+		}
+		if len(ns) != 1 {
+			t.Errorf("%s: expected 1 statement, got %d", src, len(ns))
+		}
+	}
+
+	for _, test := range []struct {
+		src, err string
+	}{
+		{"FOR EACH ROW IN (SELECT 1 AS x) DO SELECT @x; END; SELECT 2; END FOR;", "END cannot be used for COMMIT before END FOR"},
+		{"TRY SELECT 1; END TRANSACTION; CATCH SELECT 2; END TRY;", "END cannot be used for COMMIT before END TRY"},
+		{"IF (SELECT 1) THEN SELECT 1; END; END IF;", "END cannot be used for COMMIT before END IF"},
+		{"IF (SELECT 1) THEN SELECT 1; END FOR;", "expected END IF"},
+		{"FOR EACH ROW IN (SELECT 1 AS x) DO SELECT 1; END;", "END cannot be used for COMMIT before END FOR"},
+		{"TRY SELECT 1; END TRY;", "expected CATCH"},
+	} {
+		_, err := nodes(test.src)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: expected error %q, got: %v", test.src, test.err, err)
+		}
+	}
+}
+
+//TestIncludeErrorStopsLexer tests that an error in an included file
+//does not leave its lexer running.
+func TestIncludeErrorStopsLexer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "etlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	inc := filepath.Join(dir, "inc.etl")
+	err = ioutil.WriteFile(inc, []byte("SELECT 1;\nIF (SELECT 1) THEN SELECT 2; END FOR;\nSELECT 3;\nSELECT 4;\n"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := runtime.NumGoroutine()
+	if _, err := nodes("INCLUDE '" + inc + "';"); err == nil {
+		t.Fatal("expected error from included file")
+	}
+	for i := 0; runtime.NumGoroutine() > before; i++ {
+		if i == 100 {
+			t.Fatalf("expected at most %d goroutines, got %d", before, runtime.NumGoroutine())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -82,7 +82,7 @@ func (p *sqlParser) chkSysReserved(name ast.Name) {
 	if name.Reserved() {
 		//this fails if it relies on object resolution but catches some misuse.
 		//TODO could insert a flag in the AST to check sys names exist if length 1 and name[0] ∈ {args, env}?
-		panic(p.errMsg(name.ObjectToken(), "sys.args and sys.env are reserved by etlite"))
+		panic(p.errMsg(name.ObjectToken(), "sys.args, sys.env, and sys.vars are reserved by etlite"))
 	}
 }
 
@@ -113,8 +113,8 @@ func (p *sqlParser) top(t token.Value, subq, etl bool) {
 	}
 
 	//Forbidden statements
-	if t.AnyLiteral("ANALYZE", "EXPLAIN", "ROLLBACK") {
-		panic(p.errMsg(t, "ANALYZE and EXPLAIN and ROLLBACK are not allowed"))
+	if t.AnyLiteral("ANALYZE", "EXPLAIN") {
+		panic(p.errMsg(t, "ANALYZE and EXPLAIN are not allowed"))
 	}
 
 	if t.Literal("SAVEPOINT") {
@@ -149,6 +149,14 @@ func (p *sqlParser) top(t token.Value, subq, etl bool) {
 		return
 	}
 
+	if t.Literal("ROLLBACK") {
+		if subq {
+			panic(p.unexpected(t))
+		}
+		p.rollback(t)
+		return
+	}
+
 	//These are very simple and we just need to make sure nothing's obviously wrong
 	//while seeking ;
 	if t.AnyLiteral("VACCUM", "REINDEX", "PRAGMA") {
@@ -314,6 +322,35 @@ func (p *sqlParser) endTransaction(t token.Value) {
 	p.push(t)
 }
 
+//ROLLBACK [TRANSACTION] [TO [SAVEPOINT] name]
+func (p *sqlParser) rollback(t token.Value) {
+	p.sql.Kind = ast.Rollback
+	p.push(t)
+	t = p.next()
+	if t.Literal("TRANSACTION") {
+		p.push(t)
+		t = p.next()
+	}
+	if t.Literal("TO") {
+		p.push(t)
+		t = p.expectLitOrStr()
+		if t.Literal("SAVEPOINT") {
+			p.push(t)
+			t = p.expectLitOrStr()
+		}
+		if s, _ := t.Unescape(); digital.String(s) {
+			panic(p.errMsg(t, "digital savepoint names are reserved by etlite"))
+		}
+		p.sql.Name = astName(t)
+		p.push(t)
+		t = p.next()
+	}
+	if t.Kind != token.Semicolon {
+		panic(p.unexpected(t))
+	}
+	p.push(t)
+}
+
 //slurp simple statements until semicolon, making sure nothing untoward happens.
 func (p *sqlParser) slurp(t token.Value) {
 	p.sql.Kind = ast.Exec
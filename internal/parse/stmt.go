@@ -1,7 +1,10 @@
 package parse
 
 import (
+	"strings"
+
 	"github.com/jimmyfrasche/etlite/internal/ast"
+	"github.com/jimmyfrasche/etlite/internal/internal/digital"
 	"github.com/jimmyfrasche/etlite/internal/internal/errusr"
 	"github.com/jimmyfrasche/etlite/internal/token"
 )
@@ -21,6 +24,14 @@ func (p *parser) parseETL(t token.Value) ast.Node {
 	case "IMPORT":
 		i, _ := p.importStmt(t, false, true, nil)
 		return i
+	case "SET":
+		return p.setStmt(t)
+	case "IF":
+		return p.ifStmt(t)
+	case "FOR":
+		return p.forEachStmt(t)
+	case "TRY":
+		return p.tryStmt(t)
 	default:
 		return p.parseSQL(t, false, true)
 	}
@@ -64,9 +75,7 @@ func (p *parser) assertStmt(t token.Value) *ast.Assert {
 	default:
 		panic(p.expected("@ or subquery", t))
 	case token.LParen:
-		a.Subquery = p.parseSQL(t, true, false)
-		//trim off ()
-		a.Subquery.Tokens = a.Subquery.Tokens[1 : len(a.Subquery.Tokens)-1]
+		a.Subquery = p.subquery(t)
 	case token.Argument:
 		a.Subquery = &ast.SQL{
 			Tokens: []token.Value{t},
@@ -78,31 +87,78 @@ func (p *parser) assertStmt(t token.Value) *ast.Assert {
 	return a
 }
 
+//SET TIMEOUT duration|NONE
+//SET @name = (subquery)|'literal'
+func (p *parser) setStmt(t token.Value) ast.Node {
+	pos := t.Position
+	t = p.next()
+	if t.Kind == token.Argument {
+		return p.setVar(pos, t)
+	}
+	if !t.Literal("TIMEOUT") {
+		panic(p.expected("TIMEOUT or @ variable", t))
+	}
+	s := &ast.SetTimeout{
+		Position: pos,
+	}
+	s.Timeout, t = p.duration(p.next())
+	if t.Kind != token.Semicolon {
+		panic(p.expected(token.Semicolon, t))
+	}
+	return s
+}
+
+func (p *parser) setVar(pos token.Position, name token.Value) *ast.SetVar {
+	if digital.String(name.Value) {
+		panic(p.errMsg(name, "cannot SET command line argument @%s", name.Value))
+	}
+	s := &ast.SetVar{
+		Position: pos,
+		Name:     name,
+	}
+	p.expectLit("=")
+
+	t := p.next()
+	switch {
+	case t.Kind == token.LParen:
+		s.Subquery = p.subquery(t)
+	case t.Kind == token.String && t.StringKind == '\'':
+		s.Value = t
+	default:
+		panic(p.expected("subquery or string", t))
+	}
+
+	p.expect(token.Semicolon)
+	return s
+}
+
 //DISPLAY [TO device] [AS format] [FRAME name]
 func (p *parser) displayStmt(t token.Value) *ast.Display {
 	d := &ast.Display{
 		Position: t.Position,
 	}
+	t = p.next()
 	if t.Literal("TO") {
 		d.Device, t = p.deviceExpr(t)
 	}
-	d.Frame, t = p.frameExpr(t)
 	if t.Literal("AS") {
 		d.Format, t = p.formatExpr(p.next())
 	}
+	d.Frame, t = p.frameExpr(t)
 	if t.Kind != token.Semicolon {
 		panic(p.expected(token.Semicolon, t))
 	}
 	return d
 }
 
-//IMPORT [TEMP] [table] [header] [FROM device] [WITH format] [FRAME name] [LIMIT n] [OFFSET n]
+//IMPORT [TEMP] [table] [header] [FROM device] [WITH format] [FRAME name] [INFER TYPES [n]] [LIMIT n] [OFFSET n]
 func (p *parser) importStmt(t token.Value, subquery, compound bool, sql *ast.SQL) (ast.Node, token.Value) {
 	i := &ast.Import{
-		Position: t.Position,
-		Header:   make([]string, 0, 16),
-		Limit:    -1,
-		Offset:   -1,
+		Position:  t.Position,
+		Header:    make([]string, 0, 16),
+		Limit:     -1,
+		Offset:    -1,
+		MaxErrors: -1,
 	}
 	t = p.next()
 	if t.AnyLiteral("TEMP", "TEMPORARY") {
@@ -110,7 +166,7 @@ func (p *parser) importStmt(t token.Value, subquery, compound bool, sql *ast.SQL
 		t = p.next()
 	}
 
-	if t.Kind == token.Literal && !t.AnyLiteral("FROM", "WITH", "FRAME", "LIMIT", "OFFSET", "UNION", "INTERSECT", "EXCEPT") {
+	if t.Kind == token.Literal && !t.AnyLiteral("FROM", "WITH", "FRAME", "INFER", "LIMIT", "OFFSET", "ON", "MAX", "REJECTS", "UNION", "INTERSECT", "EXCEPT") {
 		var name ast.Name
 		t, _, name = p.name(t)
 		if name.OnTemp() {
@@ -129,6 +185,7 @@ func (p *parser) importStmt(t token.Value, subquery, compound bool, sql *ast.SQL
 	//slurp header
 	if t.Kind == token.LParen {
 		t = p.next()
+		typed := false
 		for {
 			f, ok := t.Unescape()
 			if !ok {
@@ -136,7 +193,11 @@ func (p *parser) importStmt(t token.Value, subquery, compound bool, sql *ast.SQL
 			}
 			i.Header = append(i.Header, f)
 
-			t = p.next()
+			var typ string
+			typ, t = p.columnType(p.next())
+			i.Types = append(i.Types, typ)
+			typed = typed || typ != ""
+
 			if t.Kind == token.RParen {
 				t = p.next()
 				break
@@ -147,6 +208,9 @@ func (p *parser) importStmt(t token.Value, subquery, compound bool, sql *ast.SQL
 			}
 			t = p.next()
 		}
+		if !typed {
+			i.Types = nil
+		}
 	}
 
 	if t.Literal("FROM") {
@@ -159,6 +223,19 @@ func (p *parser) importStmt(t token.Value, subquery, compound bool, sql *ast.SQL
 
 	i.Frame, t = p.frameExpr(t)
 
+	if t.Literal("INFER") {
+		p.expectLit("TYPES")
+		i.InferTypes = defaultInferSample
+		t = p.next()
+		if t.Kind == token.Literal && t.Value != "" && '0' <= t.Value[0] && t.Value[0] <= '9' {
+			n := t
+			i.InferTypes, t = p.int(t)
+			if i.InferTypes <= 0 {
+				panic(p.expected("a positive number of rows to sample", n))
+			}
+		}
+	}
+
 	if t.Literal("LIMIT") {
 		i.Limit, t = p.int(p.next())
 	}
@@ -167,6 +244,37 @@ func (p *parser) importStmt(t token.Value, subquery, compound bool, sql *ast.SQL
 		i.Offset, t = p.int(p.next())
 	}
 
+	if t.Literal("ON") {
+		p.expectLit("CONFLICT")
+		t = p.next()
+		if !t.AnyLiteral("IGNORE", "REPLACE", "ABORT") {
+			panic(p.expected("IGNORE, REPLACE, or ABORT", t))
+		}
+		i.Conflict = t.Canon
+		t = p.next()
+	}
+
+	if t.Literal("MAX") {
+		p.expectLit("ERRORS")
+		n := p.next()
+		i.MaxErrors, t = p.int(n)
+		if i.MaxErrors < 0 {
+			panic(p.expected("a number of rows", n))
+		}
+	}
+
+	if t.Literal("REJECTS") {
+		p.expectLit("TO")
+		p.expectLit("FILE")
+		t = p.next()
+		nm, ok := t.Unescape()
+		if !ok || nm == "" {
+			panic(p.expected("file name", t))
+		}
+		i.Rejects = nm
+		t = p.next()
+	}
+
 	if t.AnyLiteral("UNION", "INTERSECT", "EXCEPT") {
 		if !compound {
 			panic(p.unexpected(t))
@@ -191,6 +299,135 @@ func (p *parser) importStmt(t token.Value, subquery, compound bool, sql *ast.SQL
 	return i, t
 }
 
+//defaultInferSample is the number of rows sampled by INFER TYPES without a count.
+const defaultInferSample = 100
+
+//columnType reads the optional declared type of a column in an IMPORT header,
+//such as INTEGER or VARCHAR(20), up to the next , or ).
+func (p *parser) columnType(t token.Value) (string, token.Value) {
+	var parts []string
+	for !t.Literal(",") && t.Kind != token.RParen {
+		switch t.Kind {
+		default:
+			panic(p.unexpected(t))
+		case token.Literal:
+			parts = append(parts, t.Value)
+			t = p.next()
+		case token.LParen:
+			//type arguments, as in DECIMAL(10, 2)
+			if len(parts) == 0 {
+				panic(p.unexpected(t))
+			}
+			args := "("
+			for t = p.next(); t.Kind != token.RParen; t = p.next() {
+				switch {
+				case t.Kind != token.Literal:
+					panic(p.unexpected(t))
+				case t.Literal(","):
+					args += ", "
+				default:
+					args += t.Value
+				}
+			}
+			parts[len(parts)-1] += args + ")"
+			t = p.next()
+		}
+	}
+	return strings.Join(parts, " "), t
+}
+
+//IF (subquery) THEN statements [ELSE statements] END IF
+func (p *parser) ifStmt(t token.Value) *ast.If {
+	n := &ast.If{
+		Position: t.Position,
+	}
+	n.Cond = p.subquery(p.expect(token.LParen))
+	p.expectLit("THEN")
+
+	n.Then, t = p.block("IF")
+	if t.Literal("ELSE") {
+		n.Else, t = p.block("IF")
+	}
+	if !t.Literal("END") {
+		panic(p.expected("END IF", t))
+	}
+	p.expect(token.Semicolon)
+
+	return n
+}
+
+//FOR EACH ROW IN (subquery) DO statements END FOR
+func (p *parser) forEachStmt(t token.Value) *ast.ForEach {
+	n := &ast.ForEach{
+		Position: t.Position,
+	}
+	p.expectLit("EACH")
+	p.expectLit("ROW")
+	p.expectLit("IN")
+	n.Query = p.subquery(p.expect(token.LParen))
+	p.expectLit("DO")
+
+	n.Body, t = p.block("FOR")
+	if !t.Literal("END") {
+		panic(p.expected("END FOR", t))
+	}
+	p.expect(token.Semicolon)
+
+	return n
+}
+
+//TRY statements CATCH statements END TRY
+func (p *parser) tryStmt(t token.Value) *ast.Try {
+	n := &ast.Try{
+		Position: t.Position,
+	}
+
+	n.Body, t = p.block("TRY")
+	if !t.Literal("CATCH") {
+		panic(p.expected("CATCH", t))
+	}
+	n.Catch, t = p.block("TRY")
+	if !t.Literal("END") {
+		panic(p.expected("END TRY", t))
+	}
+	p.expect(token.Semicolon)
+
+	return n
+}
+
+//block parses statements up to ELSE, CATCH, or END kw,
+//where kw names the statement the block is in,
+//and returns the ELSE, CATCH, or END.
+//
+//SQLite accepts END [TRANSACTION] for COMMIT,
+//but in a block it is an error, so that it is not mistaken for the end of the block.
+func (p *parser) block(kw string) ([]ast.Node, token.Value) {
+	var ns []ast.Node
+	for {
+		t := p.next()
+		if t.AnyLiteral("ELSE", "CATCH") {
+			return ns, t
+		}
+		if t.Literal("END") {
+			switch k := p.next(); {
+			case k.Literal(kw):
+				return ns, t
+			case k.Kind == token.Semicolon, k.Literal("TRANSACTION"):
+				panic(p.errMsg(t, "END cannot be used for COMMIT before END %s, use COMMIT", kw))
+			default:
+				panic(p.expected("END "+kw, k))
+			}
+		}
+		if t.Literal("INCLUDE") {
+			p.include(t, func(n ast.Node) {
+				ns = append(ns, n)
+			})
+			continue
+		}
+		ns = append(ns, p.parseETL(t))
+	}
+}
+
 //Any random, regular SQL.
 func (p *parser) parseSQL(t token.Value, subquery, allowETLsq bool) *ast.SQL {
 	sp := newSqlParser(p)
@@ -198,6 +435,16 @@ func (p *parser) parseSQL(t token.Value, subquery, allowETLsq bool) *ast.SQL {
 	return sp.sql
 }
 
+//A parenthesized scalar subquery, without its parentheses.
+func (p *parser) subquery(t token.Value) *ast.SQL {
+	sp := newSqlParser(p)
+	sp.sql.Kind = ast.Query
+	_ = sp.regular(t, 0, true, false, true)
+	//trim off ()
+	sp.sql.Tokens = sp.sql.Tokens[1 : len(sp.sql.Tokens)-1]
+	return sp.sql
+}
+
 func (p *parser) liftSQL(t token.Value, i *ast.Import) (*ast.SQL, token.Value) {
 	sp := newSqlParser(p)
 	sp.synth(t, token.Placeholder) //for the import
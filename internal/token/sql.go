@@ -43,6 +43,10 @@ var headLiterals = [...]string{
 	"ASSERT",
 	"DISPLAY",
 	"IMPORT",
+	"SET",
+	"IF",
+	"FOR",
+	"TRY",
 	"SELECT",
 	"INSERT",
 	"UPDATE",
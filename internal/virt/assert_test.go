@@ -0,0 +1,22 @@
+package virt_test
+
+import (
+	"strings"
+	"testing"
+)
+
+//TestAssertArg tests that ASSERT msg, @x passes only if x is set.
+func TestAssertArg(t *testing.T) {
+	err := run(t, `SET @x = 'v';
+ASSERT 'need x', @x;
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = run(t, `ASSERT 'need y', @y;
+`)
+	if err == nil || !strings.Contains(err.Error(), "need y") {
+		t.Fatal("expected assertion failure, got:", err)
+	}
+}
@@ -0,0 +1,288 @@
+package virt
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/jimmyfrasche/etlite/internal/internal/errint"
+	"github.com/jimmyfrasche/etlite/internal/internal/errsys"
+	"github.com/jimmyfrasche/etlite/internal/internal/errusr"
+)
+
+//NewCheck creates an execution context for checking a script
+//without running it.
+//
+//The context uses an in-memory copy of the schema of db,
+//if db exists, and an empty database otherwise.
+//
+//Instructions run in a checking context only prepare their SQL,
+//except for statements that change the schema, which are run
+//against the copy so that later statements may be prepared.
+//ATTACH attaches an in-memory copy of the schema of its database.
+//Devices and formats are never opened and no data is read or written.
+func NewCheck(db string, args, env []string) (*Machine, error) {
+	m, err := New(":memory:", args, env)
+	if err != nil {
+		return nil, err
+	}
+	m.dry = true
+	m.unknown = map[string]bool{}
+
+	if db == "" || db == ":memory:" {
+		return m, nil
+	}
+	m.name = db
+	if _, err := os.Stat(db); err != nil {
+		//USE creates db, so it starts out empty
+		return m, nil
+	}
+	if err := m.copySchema(db, "main"); err != nil {
+		m.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+//copySchema recreates the tables, indices, views, and triggers of db
+//in the database schema.
+func (m *Machine) copySchema(db, schema string) error {
+	q := "'" + strings.Replace(db, "'", "''", -1) + "'"
+	if err := m.exec("ATTACH " + q + " AS etlite_schema"); err != nil {
+		return err
+	}
+	type object struct {
+		typ, name, sql string
+	}
+	var objs []object
+	err := func() error {
+		s, err := m.conn.Prepare(`SELECT type, name, sql FROM etlite_schema.sqlite_master
+WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite_%' ORDER BY rowid`)
+		if err != nil {
+			return errint.Wrap(err)
+		}
+		defer s.Close()
+
+		it, err := s.Iter()
+		if err != nil {
+			return err
+		}
+		for it.Next() {
+			r := it.Row()
+			objs = append(objs, object{r[0].Text, r[1].Text, r[2].Text})
+		}
+		return it.Err()
+	}()
+	if derr := m.exec("DETACH etlite_schema"); err == nil {
+		err = derr
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, o := range objs {
+		//creating a virtual table creates its shadow tables
+		if o.typ == "table" {
+			exists, err := m.exists(schema, o.name)
+			if err != nil {
+				return err
+			}
+			if exists {
+				continue
+			}
+		}
+		if err := m.exec(qualify(o.sql, schema)); err != nil {
+			return errsys.WrapWith("copying schema of "+db+":", err)
+		}
+	}
+	return nil
+}
+
+//qualify the name of the object created by sql with schema.
+//
+//SQLite stores the sql of an object normalized so that
+//the name directly follows the CREATE keywords.
+func qualify(sql, schema string) string {
+	for _, create := range [...]string{
+		"CREATE TABLE ",
+		"CREATE VIRTUAL TABLE ",
+		"CREATE VIEW ",
+		"CREATE INDEX ",
+		"CREATE UNIQUE INDEX ",
+		"CREATE TRIGGER ",
+	} {
+		if strings.HasPrefix(sql, create) {
+			return create + schema + "." + sql[len(create):]
+		}
+	}
+	return sql
+}
+
+//exists reports whether name is in the database schema.
+func (m *Machine) exists(schema, name string) (bool, error) {
+	s, err := m.conn.Prepare("SELECT count(*) FROM " + schema + ".sqlite_master WHERE name = '" + strings.Replace(name, "'", "''", -1) + "'")
+	if err != nil {
+		return false, errint.Wrap(err)
+	}
+	defer s.Close()
+	it, err := s.Iter()
+	if err != nil {
+		return false, err
+	}
+	if !it.Next() {
+		return false, it.Err()
+	}
+	return it.Row()[0].Text != "0", nil
+}
+
+var attachRE = regexp.MustCompile(`(?is)^\s*ATTACH\s*(?:DATABASE\b)?(.+)\bAS\b\s*(\S+?)\s*;?\s*$`)
+
+//checkAttach attaches an in-memory database in place of
+//the database attached by q, with a copy of its schema if it exists,
+//so that the script may use its tables without modifying it.
+func (m *Machine) checkAttach(q string) error {
+	sm := attachRE.FindStringSubmatch(q)
+	if sm == nil {
+		return m.prepare(q)
+	}
+	file, schema := sm[1], sm[2]
+	if err := m.exec("ATTACH ':memory:' AS " + schema); err != nil {
+		return err
+	}
+
+	db, err := m.fileName("SELECT " + file)
+	if err != nil || db == ":memory:" {
+		//the database is computed from something unknown or is empty
+		return nil
+	}
+	if _, err := os.Stat(db); err != nil {
+		//ATTACH creates db, so it starts out empty
+		return nil
+	}
+	return m.copySchema(db, schema)
+}
+
+//Check executes all is instructions in a checking context
+//created by NewCheck.
+//
+//Unlike Run, it continues after an error to report as many problems
+//as possible.
+func (m *Machine) Check(ctx context.Context, is []Instruction) []error {
+	if !m.dry {
+		return []error{errint.New("Check requires a context created by NewCheck")}
+	}
+	var errs []error
+	for _, i := range is {
+		if err := m.step(ctx, i); err != nil {
+			errs = append(errs, errusr.Wrap(m.pos, err))
+		}
+		select {
+		case <-ctx.Done():
+			return append(errs, ctx.Err())
+		default:
+		}
+	}
+	return errs
+}
+
+//prepare q without running it.
+func (m *Machine) prepare(q string) error {
+	s, err := m.conn.Prepare(q)
+	if err != nil {
+		if m.dependsOnUnknown(q, err) {
+			return nil
+		}
+		return err
+	}
+	return s.Close()
+}
+
+//checkExec prepares q and runs it, if it changes the schema.
+//An ATTACH is replaced by checkAttach.
+func (m *Machine) checkExec(q string) error {
+	if keyword(q) == "ATTACH" {
+		return m.checkAttach(q)
+	}
+	if !changesSchema(q) {
+		return m.prepare(q)
+	}
+	if err := m.exec(q); err != nil && !m.dependsOnUnknown(q, err) {
+		return err
+	}
+	return nil
+}
+
+func changesSchema(q string) bool {
+	switch keyword(q) {
+	case "CREATE", "DROP", "ALTER", "DETACH":
+		return true
+	}
+	return false
+}
+
+//keyword returns the first keyword of q in upper case.
+func keyword(q string) string {
+	q = strings.TrimLeftFunc(q, unicode.IsSpace)
+	i := strings.IndexFunc(q, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	if i < 0 {
+		i = len(q)
+	}
+	return strings.ToUpper(q[:i])
+}
+
+//dependsOnUnknown reports whether err, from preparing q, may be caused by
+//a table whose columns are read from the input and so
+//cannot be known without running the script.
+func (m *Machine) dependsOnUnknown(q string, err error) bool {
+	if len(m.unknown) == 0 {
+		return false
+	}
+	msg := err.Error()
+	if strings.HasPrefix(msg, "no such column: ") {
+		return m.usesUnknown(q)
+	}
+	const noTable = "no such table: "
+	if !strings.HasPrefix(msg, noTable) {
+		return false
+	}
+	name := msg[len(noTable):]
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return m.unknown[unbracket(name)]
+}
+
+//usesUnknown reports whether any name in q is an unknown table.
+//
+//It may be fooled by a column or alias with the name of an unknown table,
+//which only results in an error not being reported.
+func (m *Machine) usesUnknown(q string) bool {
+	names := strings.FieldsFunc(q, func(r rune) bool {
+		return !(r == '_' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r))
+	})
+	for _, name := range names {
+		if m.unknown[strings.ToLower(name)] {
+			return true
+		}
+	}
+	return false
+}
+
+//unbracket normalizes a table name for m.unknown,
+//removing any quotes or brackets around it.
+func unbracket(name string) string {
+	return strings.ToLower(strings.Trim(name, "[]\"`'"))
+}
+
+//skipUnknown records that the columns of table cannot be known
+//when checking.
+func (m *Machine) skipUnknown(table string) {
+	if i := strings.LastIndex(table, "."); i >= 0 {
+		table = table[i+1:]
+	}
+	m.unknown[unbracket(table)] = true
+}
@@ -0,0 +1,82 @@
+package virt_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jimmyfrasche/etlite/internal/compile"
+	"github.com/jimmyfrasche/etlite/internal/lex"
+	"github.com/jimmyfrasche/etlite/internal/parse"
+	"github.com/jimmyfrasche/etlite/internal/virt"
+)
+
+//check compiles the script src and checks it against db.
+func check(t *testing.T, db, src string) []error {
+	_, is, err := compile.Nodes(parse.Tokens(lex.Stream("test", strings.NewReader(src))), false)
+	if err != nil {
+		t.Fatal("could not compile script, got:", err)
+	}
+	m, err := virt.NewCheck(db, nil, nil)
+	if err != nil {
+		t.Fatal("could not create machine, got:", err)
+	}
+	errs := m.Check(context.Background(), is)
+	return append(errs, m.Close()...)
+}
+
+//TestCheckAttach tests that checking a script uses the schema
+//of the databases it attaches without modifying them.
+func TestCheckAttach(t *testing.T) {
+	dir, err := ioutil.TempDir("", "etlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	other := filepath.Join(dir, "other.db")
+	err = run(t, `ATTACH '`+other+`' AS other;
+CREATE TABLE other.t (a);
+CREATE VIEW other.v AS SELECT a FROM t;
+DETACH other;
+`)
+	if err != nil {
+		t.Fatal("could not create attached database, got:", err)
+	}
+
+	errs := check(t, "", `ATTACH '`+other+`' AS other;
+SELECT a FROM other.t;
+SELECT a FROM other.v;
+CREATE TABLE other.u (b);
+INSERT INTO other.u (b) SELECT a FROM other.t;
+DETACH other;
+ATTACH '`+filepath.Join(dir, "new.db")+`' AS new;
+CREATE TABLE new.t (c);
+SELECT c FROM new.t;
+`)
+	for _, err := range errs {
+		t.Error(err)
+	}
+
+	errs = check(t, "", `ATTACH '`+other+`' AS other;
+SELECT b FROM other.t;
+DETACH other;
+SELECT a FROM other.t;
+`)
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %d: %v", len(errs), errs)
+	}
+
+	err = run(t, `ATTACH '`+other+`' AS other;
+ASSERT 'unmodified', (SELECT count(*) = 2 FROM other.sqlite_master);
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "new.db")); err == nil {
+		t.Fatal("checking created new.db")
+	}
+}
@@ -3,12 +3,29 @@ package virt
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"strings"
 
+	"github.com/jimmyfrasche/etlite/internal/driver"
 	"github.com/jimmyfrasche/etlite/internal/internal/errint"
+	"github.com/jimmyfrasche/etlite/internal/internal/infer"
 	"github.com/jimmyfrasche/etlite/internal/internal/synth"
+	"github.com/jimmyfrasche/etlite/internal/internal/value"
 )
 
+//SourceColumn is the column holding the name of the file
+//each row was imported from when importing FILES with SOURCE.
+const SourceColumn = "_source"
+
+//withSource adds SourceColumn to hdr, if requested.
+func (m *Machine) withSource(hdr []string) []string {
+	if !m.files.source {
+		return hdr
+	}
+	return append(hdr[:len(hdr):len(hdr)], SourceColumn)
+}
+
 func (m *Machine) readHeader(frame string, header []string) ([]string, error) {
 	dec := m.decoder
 	if dec == nil {
@@ -18,40 +35,170 @@ func (m *Machine) readHeader(frame string, header []string) ([]string, error) {
 	if err != nil {
 		return nil, err
 	}
+	if m.rejects != nil {
+		m.rejects.hdr = m.withSource(inHeader)
+	}
 	return inHeader, nil
 }
 
-func Import(temp bool, table, frame string, limit, offset int) Instruction {
-	return func(ctx context.Context, m *Machine) error {
-		hdr, err := m.readHeader(frame, nil)
+//Import creates table and imports into it.
+//
+//If header is not provided, it is read from the input.
+//If infer is positive, up to that many rows are read to infer the type
+//of any column without a type in types.
+//If conflict is not empty, it is the conflict resolution algorithm
+//used to insert each row.
+func Import(temp bool, table, frame string, header, types []string, infer, limit, offset int, conflict string) Instruction {
+	return Instruction{
+		Op:       OpImport,
+		Operands: []Operand{{"temp", temp}, {"table", table}, {"frame", frame}, {"header", header}, {"types", types}, {"infer", infer}, {"limit", limit}, {"offset", offset}, {"conflict", conflict}},
+		exec: func(ctx context.Context, m *Machine) (err error) {
+			if m.dry {
+				if len(header) == 0 {
+					m.skipUnknown(table)
+					return nil
+				}
+				return m.checkExec(synth.CreateTable(temp, table, m.withSource(header), types))
+			}
+			defer func() {
+				if cerr := m.closeRejects(); err == nil {
+					err = cerr
+				}
+			}()
+			hdr, err := m.readHeader(frame, header)
+			if err != nil {
+				return err
+			}
+
+			if len(hdr) == 0 {
+				return errors.New("no header specified and none returned by " + m.decoder.Name() + " format")
+			}
+
+			var sample []inputRow
+			skip, typs := offset, types
+			if infer > 0 {
+				if skip > 0 {
+					if err := m.decoder.Skip(skip); err != nil {
+						return err
+					}
+					skip = 0
+				}
+				n := infer
+				if limit > 0 && limit < n {
+					n = limit
+				}
+				sample, typs, err = m.inferTypes(hdr, types, n)
+				if err != nil {
+					return err
+				}
+			}
+
+			ddl := synth.CreateTable(temp, table, m.withSource(hdr), typs)
+			if err := m.exec(ddl); err != nil {
+				return err
+			}
+
+			ins := synth.Insert(conflict, table, m.withSource(hdr))
+			if err := m.bulkInsert(ctx, table, ins, sample, limit, skip); err != nil {
+				return err
+			}
+			return m.importFiles(ctx, table, frame, ins, header, hdr, limit, offset)
+		},
+	}
+}
+
+//inferTypes reads up to n rows and uses them to fill in any missing types.
+//The rows read are returned so that they may be imported.
+func (m *Machine) inferTypes(hdr, types []string, n int) ([]inputRow, []string, error) {
+	var (
+		sample []inputRow
+		ts     infer.Types
+	)
+	for len(sample) < n {
+		row, err := m.readRow()
+		if err == io.EOF {
+			break
+		}
 		if err != nil {
-			return err
+			return nil, nil, err
 		}
+		m.rows.read++
+		//the decoder may reuse row
+		row.vals = append([]*string(nil), row.vals...)
+		sample = append(sample, row)
+		ts.Add(row.vals)
+	}
 
-		if len(hdr) == 0 {
-			return errors.New("no header specified and none returned by " + m.decoder.Name() + " format")
+	inferred := ts.Affinities(len(hdr))
+	for i := range inferred {
+		if i < len(types) && types[i] != "" {
+			inferred[i] = types[i]
 		}
+	}
+	return sample, inferred, nil
+}
 
-		ddl := synth.CreateTable(temp, table, hdr)
-		if err := m.exec(ddl); err != nil {
+func InsertWith(table, frame, inserter string, header []string, limit, offset int) Instruction {
+	return Instruction{
+		Op:       OpInsertWith,
+		Operands: []Operand{{"table", table}, {"frame", frame}, {"sql", inserter}, {"header", header}, {"limit", limit}, {"offset", offset}},
+		exec: func(ctx context.Context, m *Machine) (err error) {
+			if m.dry {
+				return m.prepare(inserter)
+			}
+			defer func() {
+				if cerr := m.closeRejects(); err == nil {
+					err = cerr
+				}
+			}()
+			hdr, err := m.readHeader(frame, header)
+			if err != nil {
+				return err
+			}
+			if err := m.bulkInsert(ctx, table, inserter, nil, limit, offset); err != nil {
+				return err
+			}
+			return m.importFiles(ctx, table, frame, inserter, header, hdr, limit, offset)
+		},
+	}
+}
+
+//importFiles imports the remaining files of a FILES device, if any,
+//into table, ensuring each has the same header, hdr, as the first.
+func (m *Machine) importFiles(ctx context.Context, table, frame, ins string, header, hdr []string, limit, offset int) error {
+	for len(m.files.names) > 0 {
+		if err := m.nextFile(); err != nil {
+			return err
+		}
+		got, err := m.readHeader(frame, header)
+		if err != nil {
+			return err
+		}
+		if !sameHeader(hdr, got) {
+			return fmt.Errorf("header of %s (%s) does not match header of previous files (%s)",
+				m.input.Name(), strings.Join(got, ", "), strings.Join(hdr, ", "))
+		}
+		if err := m.bulkInsert(ctx, table, ins, nil, limit, offset); err != nil {
 			return err
 		}
-
-		ins := synth.Insert(table, hdr)
-		return m.bulkInsert(ctx, table, ins, limit, offset)
 	}
+	return nil
 }
 
-func InsertWith(table, frame, inserter string, header []string, limit, offset int) Instruction {
-	return func(ctx context.Context, m *Machine) error {
-		if _, err := m.readHeader(frame, header); err != nil {
-			return err
+func sameHeader(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
 		}
-		return m.bulkInsert(ctx, table, inserter, limit, offset)
 	}
+	return true
 }
 
-func (m *Machine) bulkInsert(ctx context.Context, name, ins string, limit, offset int) error {
+//bulkInsert the rows in pending, followed by the rest of the input.
+func (m *Machine) bulkInsert(ctx context.Context, name, ins string, pending []inputRow, limit, offset int) error {
 	//make sure we have a decoder
 	dec := m.decoder
 	if dec == nil {
@@ -69,6 +216,7 @@ func (m *Machine) bulkInsert(ctx context.Context, name, ins string, limit, offse
 	if err != nil {
 		return err
 	}
+	var lines lineQueue
 
 	if offset > 0 {
 		if err := dec.Skip(offset); err != nil {
@@ -76,16 +224,43 @@ func (m *Machine) bulkInsert(ctx context.Context, name, ins string, limit, offse
 		}
 	}
 
-	for rows := 0; limit > 0 && rows == limit || true; rows++ {
-		row, err := dec.ReadRow()
-		if err == io.EOF {
-			break
+	//scratch space for the values of each row, with its source
+	var (
+		src *value.Value
+		acc []value.Value
+	)
+	if m.files.source {
+		nm := value.String(m.input.Name())
+		src = &nm
+	}
+
+	for rows := 0; limit <= 0 || rows < limit; rows++ {
+		var row inputRow
+		if len(pending) > 0 {
+			row, pending = pending[0], pending[1:]
+		} else {
+			row, err = m.readRow()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			m.rows.read++
 		}
-		if err != nil {
-			return err
+
+		acc = acc[:0]
+		for _, v := range row.vals {
+			acc = append(acc, value.Of(v))
+		}
+		if src != nil {
+			acc = append(acc, *src)
 		}
 
-		if err := bulk.Load(row); err != nil {
+		lines.push(row.line)
+		err := bulk.Load(acc)
+		m.rows.imported++
+		if err := m.loaded(bulk, &lines, err); err != nil {
 			return err
 		}
 
@@ -101,8 +276,18 @@ func (m *Machine) bulkInsert(ctx context.Context, name, ins string, limit, offse
 		}
 	}
 
-	if err := bulk.Close(); err != nil {
-		return err
+	for {
+		err := bulk.Close()
+		if _, ok := err.(*driver.RowError); !ok {
+			if err != nil {
+				return err
+			}
+			break
+		}
+		//the rows after a rejected row are still to be loaded
+		if err := m.loaded(bulk, &lines, err); err != nil {
+			return err
+		}
 	}
 
 	return dec.Reset()
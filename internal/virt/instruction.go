@@ -2,189 +2,586 @@ package virt
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
+	"github.com/jimmyfrasche/etlite/internal/device/command"
 	"github.com/jimmyfrasche/etlite/internal/device/file"
 	"github.com/jimmyfrasche/etlite/internal/device/std"
 	"github.com/jimmyfrasche/etlite/internal/format"
 	"github.com/jimmyfrasche/etlite/internal/internal/errint"
 	"github.com/jimmyfrasche/etlite/internal/internal/errusr"
+	"github.com/jimmyfrasche/etlite/internal/internal/escape"
+	"github.com/jimmyfrasche/etlite/internal/internal/savepoint"
 	"github.com/jimmyfrasche/etlite/internal/token"
 )
 
 //An Instruction is a single instruction in the VM.
-type Instruction func(context.Context, *Machine) error
+//
+//Op and Operands describe the instruction for display.
+//See Disassemble.
+type Instruction struct {
+	Op       Op
+	Operands []Operand
+	exec     func(context.Context, *Machine) error
+}
 
 //Run executes all is instructions in execution context m.
+//
+//If ctx is cancelled, any running query is interrupted,
+//any open transaction or savepoint is rolled back,
+//and any output is cancelled.
 func (m *Machine) Run(ctx context.Context, is []Instruction) error {
-	var err error
-loop:
-	for _, i := range is {
-		if err = i(ctx, m); err != nil {
-			break
-		}
-		select {
-		case <-ctx.Done():
-			err = ctx.Err()
-			break loop
-		default:
+	stop := m.interruptOn(ctx)
+	err := m.run(ctx, is)
+	stop()
+	_, timedOut := err.(statementTimeout)
+	cancelled := err != nil && (ctx.Err() != nil || timedOut)
+	if cancelled && !timedOut {
+		//report why we stopped rather than SQLite being interrupted
+		err = ctx.Err()
+		if err == context.DeadlineExceeded {
+			err = errors.New("script timed out")
 		}
 	}
+	m.endStat()
 	if err != nil {
-		if m.stack.Open() {
+		if m.stack.Open() || cancelled {
 			_ = m.drain(true)
-			//TODO handle SQLITE_BUSY somewhere
-			_ = m.exec("ROLLBACK;")
+			if m.stack.Open() {
+				//TODO handle SQLITE_BUSY somewhere
+				_ = m.exec("ROLLBACK;")
+				m.stack = savepoint.New()
+			}
+			//discard the cancelled output
+			_ = m.setOutput(std.Out)
 		}
+		_ = m.flushStats()
 		return errusr.Wrap(m.pos, err)
 	}
+	if err := m.flushStats(); err != nil {
+		return err
+	}
+	//outputs written in a transaction left open by is
+	//are closed by the instructions that end it.
+	if m.stack.Open() {
+		return nil
+	}
 	return m.drain(false)
 }
 
-//Assert returns an assertion.
-func Assert(msg, query string) Instruction {
-	return func(ctx context.Context, m *Machine) error {
-		ret, err := m.conn.Assert(query)
-		if err != nil {
+func (m *Machine) run(ctx context.Context, is []Instruction) (err error) {
+	//discard any loops or TRY blocks left by a failed run
+	m.loops, m.tries = nil, nil
+
+	//each statement runs in its own context, if it has a timeout
+	sctx, end := ctx, func() {}
+	defer func() {
+		end()
+	}()
+	for pc := 0; pc < len(is); pc = m.next {
+		i := is[pc]
+		m.next = pc + 1
+		if i.Op == OpStatement {
+			end()
+			sctx, end = m.statement(ctx)
+		}
+		err = m.step(sctx, i)
+		if err == nil {
+			err = sctx.Err()
+		}
+		if err == nil {
+			continue
+		}
+		//an interrupted or timed out script cannot be caught
+		if ctx.Err() != nil {
 			return err
 		}
-		if !ret {
-			return fmt.Errorf("assertion failure: %s", msg)
+		if sctx.Err() == context.DeadlineExceeded {
+			err = statementTimeout(m.timeout)
 		}
-		return nil
+		if len(m.tries) == 0 {
+			return err
+		}
+		if err = m.catch(err); err != nil {
+			return err
+		}
+		//the CATCH block does not inherit the deadline of the failed statement
+		end()
+		sctx, end = ctx, func() {}
+	}
+	return nil
+}
+
+//statementTimeout is the error of a statement that ran longer than allowed.
+type statementTimeout time.Duration
+
+func (s statementTimeout) Error() string {
+	return fmt.Sprintf("statement timed out after %s", time.Duration(s))
+}
+
+//statement returns the context of a statement
+//and a function that must be called when the statement completes.
+func (m *Machine) statement(ctx context.Context) (context.Context, func()) {
+	if m.timeout <= 0 {
+		return ctx, func() {}
+	}
+	sctx, cancel := context.WithTimeout(ctx, m.timeout)
+	stop := m.interruptOn(sctx)
+	return sctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+//interruptOn interrupts SQLite when ctx is done, until stop is called.
+func (m *Machine) interruptOn(ctx context.Context) (stop func()) {
+	done, finished := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(finished)
+		select {
+		case <-ctx.Done():
+			m.conn.Interrupt()
+		case <-done:
+		}
+	}()
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+//SetTimeout bounds the runtime of each following statement by d.
+//If d is 0, there is no bound.
+func SetTimeout(d time.Duration) Instruction {
+	return Instruction{
+		Op:       OpSetTimeout,
+		Operands: []Operand{{"timeout", d}},
+		exec: func(ctx context.Context, m *Machine) error {
+			m.timeout = d
+			return nil
+		},
+	}
+}
+
+//SetVar sets the script variable name to the value of the SQL expression expr.
+func SetVar(name, expr string) Instruction {
+	q := "INSERT INTO sys.vars VALUES (" + escape.String(name) + ", " + expr + ")"
+	return Instruction{
+		Op:       OpSetVar,
+		Operands: []Operand{{"name", name}, {"value", expr}},
+		exec: func(ctx context.Context, m *Machine) error {
+			if m.dry {
+				return m.prepare(q)
+			}
+			return m.exec(q)
+		},
+	}
+}
+
+//Jump continues execution at instruction to.
+//
+//When checking, it does nothing, so that every instruction is checked.
+func Jump(to int) Instruction {
+	return Instruction{
+		Op:       OpJump,
+		Operands: []Operand{{"to", to}},
+		exec: func(ctx context.Context, m *Machine) error {
+			if !m.dry {
+				m.next = to
+			}
+			return nil
+		},
+	}
+}
+
+//JumpUnless continues execution at instruction to
+//unless query returns true.
+//As with Assert, query must return a single 0 or 1.
+//
+//When checking, it only prepares query.
+func JumpUnless(query string, to int) Instruction {
+	return Instruction{
+		Op:       OpJumpUnless,
+		Operands: []Operand{{"sql", query}, {"to", to}},
+		exec: func(ctx context.Context, m *Machine) error {
+			if m.dry {
+				return m.prepare(query)
+			}
+			ok, err := m.conn.Assert(query)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				m.next = to
+			}
+			return nil
+		},
+	}
+}
+
+//Assert returns an assertion.
+func Assert(msg, query string) Instruction {
+	return Instruction{
+		Op:       OpAssert,
+		Operands: []Operand{{"message", msg}, {"sql", query}},
+		exec: func(ctx context.Context, m *Machine) error {
+			if m.dry {
+				return m.prepare(query)
+			}
+			ret, err := m.conn.Assert(query)
+			if err != nil {
+				return err
+			}
+			if !ret {
+				return fmt.Errorf("assertion failure: %s", msg)
+			}
+			return nil
+		},
 	}
 }
 
 func ErrPos(p token.Poser) Instruction {
-	return func(ctx context.Context, m *Machine) error {
-		m.pos = p.Pos()
-		return nil
+	return Instruction{
+		Op:       OpErrPos,
+		Operands: []Operand{{"pos", p.Pos()}},
+		exec: func(ctx context.Context, m *Machine) error {
+			m.pos = p.Pos()
+			return nil
+		},
 	}
 }
 
 func SetEncoder(e format.Encoder) Instruction {
-	return func(ctx context.Context, m *Machine) error {
-		return m.setEncoder(e)
+	return Instruction{
+		Op:       OpSetEncoder,
+		Operands: []Operand{{"format", e}},
+		exec: func(ctx context.Context, m *Machine) error {
+			if m.dry {
+				return nil
+			}
+			return m.setEncoder(e)
+		},
 	}
 }
 
 func SetDecoder(d format.Decoder) Instruction {
-	return func(ctx context.Context, m *Machine) error {
-		return m.setDecoder(d)
+	return Instruction{
+		Op:       OpSetDecoder,
+		Operands: []Operand{{"format", d}},
+		exec: func(ctx context.Context, m *Machine) error {
+			if m.dry {
+				return nil
+			}
+			return m.setDecoder(d)
+		},
 	}
 }
 
 //SetEncodingFrame specifies the data frame (table) to encode,
 //if applicable to the current format.
 func SetEncodingFrame(f string) Instruction {
-	return func(ctx context.Context, m *Machine) error {
-		m.eframe = f
-		return nil
+	return Instruction{
+		Op:       OpSetEncodingFrame,
+		Operands: []Operand{{"frame", f}},
+		exec: func(ctx context.Context, m *Machine) error {
+			m.eframe = f
+			return nil
+		},
 	}
 }
 
 func UseStdout() Instruction {
-	return func(ctx context.Context, m *Machine) error {
-		return m.setOutput(std.Out)
+	return Instruction{
+		Op: OpUseStdout,
+		exec: func(ctx context.Context, m *Machine) error {
+			if m.dry {
+				return nil
+			}
+			return m.setOutput(std.Out)
+		},
 	}
 }
 
 func UseStdin() Instruction {
-	return func(ctx context.Context, m *Machine) error {
-		return m.setInput(std.In)
+	return Instruction{
+		Op: OpUseStdin,
+		exec: func(ctx context.Context, m *Machine) error {
+			m.files = files{}
+			if m.dry {
+				return nil
+			}
+			return m.setInput(std.In)
+		},
 	}
 }
 
-func UseFileOutput(fname string) Instruction {
-	return func(ctx context.Context, m *Machine) error {
-		f, err := file.NewWriter(fname)
-		if err != nil {
-			return err
-		}
-		return m.setOutput(f)
+func UseFileOutput(fname string, c file.Compression) Instruction {
+	return Instruction{
+		Op:       OpUseFileOutput,
+		Operands: []Operand{{"name", fname}, {"compression", c}},
+		exec: func(ctx context.Context, m *Machine) error {
+			if m.dry {
+				return nil
+			}
+			f, err := file.NewWriter(fname, c)
+			if err != nil {
+				return err
+			}
+			return m.setOutput(f)
+		},
 	}
 }
 
-func UseFileInput(fname string) Instruction {
-	return func(ctx context.Context, m *Machine) error {
-		f, err := file.NewReader(fname)
-		if err != nil {
-			return err
-		}
-		return m.setInput(f)
+func UseFileInput(fname string, c file.Compression) Instruction {
+	return Instruction{
+		Op:       OpUseFileInput,
+		Operands: []Operand{{"name", fname}, {"compression", c}},
+		exec: func(ctx context.Context, m *Machine) error {
+			if m.dry {
+				m.files = files{}
+				return nil
+			}
+			f, err := file.NewReader(fname, c)
+			if err != nil {
+				return err
+			}
+			m.files = files{}
+			return m.setInput(f)
+		},
+	}
+}
+
+//UseFileOutputFrom writes to the file named by the result of query.
+func UseFileOutputFrom(query string, c file.Compression) Instruction {
+	return Instruction{
+		Op:       OpUseFileOutputFrom,
+		Operands: []Operand{{"sql", query}, {"compression", c}},
+		exec: func(ctx context.Context, m *Machine) error {
+			if m.dry {
+				return m.prepare(query)
+			}
+			fname, err := m.fileName(query)
+			if err != nil {
+				return err
+			}
+			return UseFileOutput(fname, c).exec(ctx, m)
+		},
+	}
+}
+
+//UseFileInputFrom reads from the file named by the result of query.
+func UseFileInputFrom(query string, c file.Compression) Instruction {
+	return Instruction{
+		Op:       OpUseFileInputFrom,
+		Operands: []Operand{{"sql", query}, {"compression", c}},
+		exec: func(ctx context.Context, m *Machine) error {
+			if m.dry {
+				m.files = files{}
+				return m.prepare(query)
+			}
+			fname, err := m.fileName(query)
+			if err != nil {
+				return err
+			}
+			return UseFileInput(fname, c).exec(ctx, m)
+		},
+	}
+}
+
+//fileName evaluates a query computing the name of a file.
+func (m *Machine) fileName(query string) (string, error) {
+	s, err := m.conn.Prepare(query)
+	if err != nil {
+		return "", err
+	}
+	defer s.Close()
+	name, err := s.Subquery()
+	if err != nil {
+		return "", err
+	}
+	if name == nil || *name == "" {
+		return "", errors.New("file name is NULL or empty")
+	}
+	return *name, nil
+}
+
+//UseCommandOutput writes to the input of command.
+func UseCommandOutput(cmd string) Instruction {
+	return Instruction{
+		Op:       OpUseCommandOutput,
+		Operands: []Operand{{"command", cmd}},
+		exec: func(ctx context.Context, m *Machine) error {
+			if m.dry {
+				return nil
+			}
+			w, err := command.NewWriter(cmd)
+			if err != nil {
+				return err
+			}
+			return m.setOutput(w)
+		},
+	}
+}
+
+//UseCommandInput reads from the output of command.
+func UseCommandInput(cmd string) Instruction {
+	return Instruction{
+		Op:       OpUseCommandInput,
+		Operands: []Operand{{"command", cmd}},
+		exec: func(ctx context.Context, m *Machine) error {
+			if m.dry {
+				m.files = files{}
+				return nil
+			}
+			r, err := command.NewReader(cmd)
+			if err != nil {
+				return err
+			}
+			m.files = files{}
+			return m.setInput(r)
+		},
+	}
+}
+
+//UseFilesInput uses each file matching pattern, in sorted order,
+//as input for the next import.
+//If source is set, SourceColumn is added to the import.
+func UseFilesInput(pattern string, c file.Compression, source bool) Instruction {
+	return Instruction{
+		Op:       OpUseFilesInput,
+		Operands: []Operand{{"pattern", pattern}, {"compression", c}, {"source", source}},
+		exec: func(ctx context.Context, m *Machine) error {
+			if m.dry {
+				m.files = files{source: source}
+				return nil
+			}
+			names, err := file.Glob(pattern)
+			if err != nil {
+				return err
+			}
+			m.files = files{
+				names:  names,
+				comp:   c,
+				source: source,
+			}
+			return m.nextFile()
+		},
 	}
 }
 
 func Savepoint() Instruction {
-	return func(ctx context.Context, m *Machine) error {
-		m.stack.Savepoint("1")
-		return m.savepointStmt.Exec()
+	return Instruction{
+		Op: OpSavepoint,
+		exec: func(ctx context.Context, m *Machine) error {
+			m.mark()
+			m.stack.Savepoint("1")
+			return m.savepointStmt.Exec()
+		},
 	}
 }
 
 func Release() Instruction {
-	return func(ctx context.Context, m *Machine) error {
-		if err := m.releaseStmt.Exec(); err != nil {
-			return err
-		}
-		m.stack.Release("1")
-		return nil
+	return Instruction{
+		Op: OpRelease,
+		exec: func(ctx context.Context, m *Machine) error {
+			if err := m.releaseStmt.Exec(); err != nil {
+				return err
+			}
+			m.stack.Release("1")
+			return nil
+		},
 	}
 }
 
 func DropTempTables(names []string) Instruction {
-	return func(ctx context.Context, m *Machine) error {
-		for _, name := range names {
-			if err := m.exec("DROP TABLE temp." + name); err != nil {
-				return err
+	return Instruction{
+		Op:       OpDropTempTables,
+		Operands: []Operand{{"tables", names}},
+		exec: func(ctx context.Context, m *Machine) error {
+			for _, name := range names {
+				q := "DROP TABLE temp." + name
+				if m.dry {
+					//the table is not created if its columns are unknown
+					q = "DROP TABLE IF EXISTS temp." + name
+				}
+				if err := m.exec(q); err != nil {
+					return err
+				}
 			}
-		}
-		return nil
+			return nil
+		},
 	}
 }
 
 func Exec(q string) Instruction {
-	return func(ctx context.Context, m *Machine) error {
-		return m.exec(q) //TODO fastpath this in driver
+	return Instruction{
+		Op:       OpExec,
+		Operands: []Operand{{"sql", q}},
+		exec: func(ctx context.Context, m *Machine) error {
+			if m.dry {
+				return m.checkExec(q)
+			}
+			return m.exec(q) //TODO fastpath this in driver
+		},
 	}
 }
 
 func BeginTransaction(q string) Instruction {
-	return func(ctx context.Context, m *Machine) error {
-		if err := m.stack.Begin(); err != nil {
-			return errint.Wrap(err)
-		}
-		return m.exec(q)
+	return Instruction{
+		Op:       OpBeginTransaction,
+		Operands: []Operand{{"sql", q}},
+		exec: func(ctx context.Context, m *Machine) error {
+			m.mark()
+			if err := m.stack.Begin(); err != nil {
+				return errint.Wrap(err)
+			}
+			return m.exec(q)
+		},
 	}
 }
 
 func CommitTransaction(q string) Instruction {
-	return func(ctx context.Context, m *Machine) error {
-		if err := m.stack.End(); err != nil {
-			return errint.Wrap(err)
-		}
-		if err := m.drain(false); err != nil {
-			return err
-		}
-		return m.exec(q)
+	return Instruction{
+		Op:       OpCommitTransaction,
+		Operands: []Operand{{"sql", q}},
+		exec: func(ctx context.Context, m *Machine) error {
+			if err := m.stack.End(); err != nil {
+				return errint.Wrap(err)
+			}
+			if err := m.drain(false); err != nil {
+				return err
+			}
+			return m.exec(q)
+		},
 	}
 }
 
 func UserSavepoint(name, q string) Instruction {
-	return func(ctx context.Context, m *Machine) error {
-		m.stack.Savepoint(name)
-		return m.exec(q)
+	return Instruction{
+		Op:       OpUserSavepoint,
+		Operands: []Operand{{"name", name}, {"sql", q}},
+		exec: func(ctx context.Context, m *Machine) error {
+			m.mark()
+			m.stack.Savepoint(name)
+			return m.exec(q)
+		},
 	}
 }
 
 func UserRelease(name, q string) Instruction {
-	return func(ctx context.Context, m *Machine) error {
-		if err := m.stack.Release(name); err != nil {
-			return errint.Wrap(err)
-		}
-		if err := m.drain(false); err != nil {
-			return err
-		}
-		return m.exec(q)
+	return Instruction{
+		Op:       OpUserRelease,
+		Operands: []Operand{{"name", name}, {"sql", q}},
+		exec: func(ctx context.Context, m *Machine) error {
+			if err := m.stack.Release(name); err != nil {
+				return errint.Wrap(err)
+			}
+			if err := m.drain(false); err != nil {
+				return err
+			}
+			return m.exec(q)
+		},
 	}
 }
@@ -2,10 +2,12 @@
 package sysdb
 
 import (
+	"strconv"
 	"strings"
 
 	"github.com/jimmyfrasche/etlite/internal/driver"
 	"github.com/jimmyfrasche/etlite/internal/internal/errint"
+	"github.com/jimmyfrasche/etlite/internal/internal/value"
 )
 
 const (
@@ -15,6 +17,21 @@ const (
 	value TEXT NOT NULL
 ) WITHOUT ROWID`
 	createArg  = `CREATE TABLE sys.args (value TEXT NOT NULL)`
+	createVars = `CREATE TABLE sys.vars (
+	name TEXT PRIMARY KEY ON CONFLICT REPLACE,
+	value
+) WITHOUT ROWID`
+	createStat = `CREATE TABLE sys.stats (
+	kind TEXT NOT NULL,
+	pos TEXT NOT NULL,
+	runs INTEGER NOT NULL,
+	read INTEGER NOT NULL,
+	loaded INTEGER NOT NULL,
+	written INTEGER NOT NULL,
+	elapsed REAL NOT NULL
+)`
+	insStat    = `INSERT INTO sys.stats VALUES (?, ?, ?, ?, ?, ?, ?)`
+	delStat    = `DELETE FROM sys.stats`
 	insEnv     = `INSERT INTO sys.env VALUES (?, ?)`
 	insArg     = `INSERT INTO sys.args VALUES (?)`
 	readAllEnv = `SELECT name || '=' || value FROM sys.env`
@@ -22,40 +39,46 @@ const (
 
 //Sysdb represents the sys db in etlite.
 type Sysdb struct {
-	readAllEnv *driver.Stmt
+	conn                *driver.Conn
+	readAllEnv, insStat *driver.Stmt
 }
 
 //New create a sys db populated with args and env.
 func New(conn *driver.Conn, args, env []string) (sys *Sysdb, err error) {
-	for _, s := range [...]string{attach, createEnv, createArg} {
+	for _, s := range [...]string{attach, createEnv, createArg, createVars, createStat} {
 		if err = exec(conn, s); err != nil {
 			return nil, err
 		}
 	}
 
-	err = load(conn, insArg, args, func(arg string) ([]*string, error) {
-		return []*string{&arg}, nil
+	err = load(conn, insArg, args, func(arg string) ([]value.Value, error) {
+		return []value.Value{value.String(arg)}, nil
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	err = load(conn, insEnv, env, func(e string) ([]*string, error) {
-		key, value, err := splitEnv(e)
+	err = load(conn, insEnv, env, func(e string) ([]value.Value, error) {
+		key, val, err := splitEnv(e)
 		if err != nil {
 			return nil, err
 		}
-		return []*string{&key, &value}, nil
+		return []value.Value{value.String(key), value.String(val)}, nil
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	sys = &Sysdb{}
+	sys = &Sysdb{conn: conn}
 	sys.readAllEnv, err = conn.Prepare(readAllEnv)
 	if err != nil {
 		return nil, errint.Wrap(err)
 	}
+	sys.insStat, err = conn.Prepare(insStat)
+	if err != nil {
+		sys.readAllEnv.Close()
+		return nil, errint.Wrap(err)
+	}
 
 	return sys, nil
 }
@@ -74,7 +97,7 @@ func exec(conn *driver.Conn, stmt string) error {
 	return nil
 }
 
-func load(conn *driver.Conn, stmt string, args []string, f func(string) ([]*string, error)) error {
+func load(conn *driver.Conn, stmt string, args []string, f func(string) ([]value.Value, error)) error {
 	if len(args) == 0 {
 		return nil
 	}
@@ -83,6 +106,7 @@ func load(conn *driver.Conn, stmt string, args []string, f func(string) ([]*stri
 	if err != nil {
 		return errint.Wrap(err)
 	}
+	defer p.Close()
 	load, err := p.Loader()
 	if err != nil {
 		return errint.Wrap(err)
@@ -98,7 +122,7 @@ func load(conn *driver.Conn, stmt string, args []string, f func(string) ([]*stri
 		}
 	}
 
-	return nil
+	return errint.Wrap(load.Close())
 }
 
 func splitEnv(e string) (key, value string, err error) {
@@ -111,7 +135,40 @@ func splitEnv(e string) (key, value string, err error) {
 
 //Close the sysdb and release
 func (s *Sysdb) Close() error {
-	return errint.Wrap(s.readAllEnv.Close())
+	err := s.readAllEnv.Close()
+	if serr := s.insStat.Close(); err == nil {
+		err = serr
+	}
+	return errint.Wrap(err)
+}
+
+//ClearStats deletes all statistics from sys.stats.
+func (s *Sysdb) ClearStats() error {
+	return exec(s.conn, delStat)
+}
+
+//AddStat records the statistics of a statement in sys.stats.
+func (s *Sysdb) AddStat(kind, pos string, runs, read, loaded, written int, elapsed float64) error {
+	load, err := s.insStat.Loader()
+	if err != nil {
+		return errint.Wrap(err)
+	}
+	integer := func(n int) value.Value {
+		return value.Value{Kind: value.Integer, Text: strconv.Itoa(n)}
+	}
+	err = load.Load([]value.Value{
+		value.String(kind),
+		value.String(pos),
+		integer(runs),
+		integer(read),
+		integer(loaded),
+		integer(written),
+		{Kind: value.Real, Text: strconv.FormatFloat(elapsed, 'f', -1, 64)},
+	})
+	if cerr := load.Close(); err == nil {
+		err = cerr
+	}
+	return errint.Wrap(err)
 }
 
 //Environ dumps sys.env (which the user is free to modify)
@@ -124,7 +181,7 @@ func (s *Sysdb) Environ() ([]string, error) {
 	var env []string
 	for i.Next() {
 		r := i.Row()[0]
-		env = append(env, *r)
+		env = append(env, r.Text)
 	}
 	if err := i.Err(); err != nil {
 		return nil, errint.Wrap(err)
@@ -0,0 +1,128 @@
+package virt
+
+import (
+	"context"
+	"encoding/hex"
+	"strings"
+
+	"github.com/jimmyfrasche/etlite/internal/internal/errint"
+	"github.com/jimmyfrasche/etlite/internal/internal/escape"
+	"github.com/jimmyfrasche/etlite/internal/internal/savepoint"
+	"github.com/jimmyfrasche/etlite/internal/internal/value"
+)
+
+//loop is the state of a FOR EACH ROW loop.
+type loop struct {
+	cols  []string
+	rows  [][]value.Value
+	next  int              //index of the next row
+	stack *savepoint.Stack //as of the start of the loop
+}
+
+//ForEach runs query and begins a loop over its rows.
+//The loop is ended by Next.
+//
+//The rows are read before the loop begins,
+//so the body of the loop may modify the tables read by query.
+//
+//When checking, it only prepares query.
+func ForEach(query string) Instruction {
+	return Instruction{
+		Op:       OpForEach,
+		Operands: []Operand{{"sql", query}},
+		exec: func(ctx context.Context, m *Machine) error {
+			if m.dry {
+				return m.prepare(query)
+			}
+			l, err := m.loop(query)
+			if err != nil {
+				return err
+			}
+			m.loops = append(m.loops, l)
+			return nil
+		},
+	}
+}
+
+func (m *Machine) loop(query string) (*loop, error) {
+	s, err := m.conn.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+
+	l := &loop{
+		cols:  s.Columns(),
+		stack: m.stack.Copy(),
+	}
+	it, err := s.Iter()
+	if err != nil {
+		return nil, err
+	}
+	for it.Next() {
+		l.rows = append(l.rows, append([]value.Value(nil), it.Row()...))
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+//Next binds the columns of the next row of the innermost loop
+//to the script variables of the same name.
+//If there are no more rows, the loop ends and execution continues
+//at instruction end.
+//
+//When checking, it does nothing, so that the body of the loop is checked once.
+func Next(end int) Instruction {
+	return Instruction{
+		Op:       OpNext,
+		Operands: []Operand{{"end", end}},
+		exec: func(ctx context.Context, m *Machine) error {
+			if m.dry {
+				return nil
+			}
+			if len(m.loops) == 0 {
+				return errint.New("Next outside of a loop")
+			}
+			l := m.loops[len(m.loops)-1]
+			//the compiler ensures this cannot happen
+			if !m.stack.Equal(l.stack) {
+				return errint.New("loop body changed the savepoint stack")
+			}
+			if l.next == len(l.rows) {
+				m.loops = m.loops[:len(m.loops)-1]
+				m.next = end
+				return nil
+			}
+			row := l.rows[l.next]
+			l.next++
+			if len(l.cols) == 0 {
+				return nil
+			}
+			return m.exec(bindVars(l.cols, row))
+		},
+	}
+}
+
+//bindVars returns the statement setting the script variables cols to row.
+func bindVars(cols []string, row []value.Value) string {
+	vs := make([]string, len(cols))
+	for i, c := range cols {
+		vs[i] = "(" + escape.String(c) + ", " + literal(row[i]) + ")"
+	}
+	return "INSERT INTO sys.vars VALUES " + strings.Join(vs, ", ")
+}
+
+//literal returns v as an SQL literal of the same storage class.
+func literal(v value.Value) string {
+	switch v.Kind {
+	case value.Null:
+		return "NULL"
+	case value.Integer, value.Real:
+		return v.Text
+	case value.Blob:
+		return "X'" + hex.EncodeToString([]byte(v.Text)) + "'"
+	}
+	return escape.String(v.Text)
+}
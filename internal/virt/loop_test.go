@@ -0,0 +1,49 @@
+package virt_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+//TestForEach tests that the body of a loop runs once for each row,
+//with the variables of the row set, including in computed file names.
+func TestForEach(t *testing.T) {
+	dir, err := ioutil.TempDir("", "etlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = run(t, fmt.Sprintf(`
+CREATE TABLE sales (region, amt);
+INSERT INTO sales (region, amt) SELECT 'east', 1 UNION ALL SELECT 'west', 2 UNION ALL SELECT 'east', 3;
+CREATE TABLE acc (region, total);
+FOR EACH ROW IN (SELECT DISTINCT region FROM sales ORDER BY region) DO
+	INSERT INTO acc (region, total) SELECT @region, sum(amt) FROM sales WHERE region = @region;
+	DISPLAY TO FILE '%s/out_' || @region || '.csv' AS CSV;
+	SELECT amt FROM sales WHERE region = @region ORDER BY amt;
+END FOR;
+ASSERT 'one row per region', (SELECT group_concat(region || total) = 'east4,west2' FROM acc);
+ASSERT 'variables of the last row', (SELECT @region = 'west');
+`, strings.Replace(dir, "'", "''", -1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for region, exp := range map[string]string{
+		"east": "amt\n1\n3\n",
+		"west": "amt\n2\n",
+	} {
+		got, err := ioutil.ReadFile(filepath.Join(dir, "out_"+region+".csv"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s := strings.Replace(string(got), "\r\n", "\n", -1); s != exp {
+			t.Errorf("%s: expected %q got %q", region, exp, s)
+		}
+	}
+}
@@ -1,7 +1,10 @@
 package virt
 
 import (
+	"time"
+
 	"github.com/jimmyfrasche/etlite/internal/device"
+	"github.com/jimmyfrasche/etlite/internal/device/file"
 	"github.com/jimmyfrasche/etlite/internal/device/std"
 	"github.com/jimmyfrasche/etlite/internal/driver"
 	"github.com/jimmyfrasche/etlite/internal/format"
@@ -29,10 +32,37 @@ type Machine struct {
 	savepointStmt, releaseStmt *driver.Stmt
 
 	eframe string
+	files  files
 
 	stack *savepoint.Stack
 	pos   token.Position
 	devs  []device.Writer
+	marks []mark //of each level of the stack
+
+	timeout time.Duration //of each statement, if positive
+	next    int           //index of the next instruction to run
+	loops   []*loop       //FOR EACH ROW loops, innermost last
+	tries   []try         //TRY blocks, innermost last
+	rejects *rejects      //of the current import, if it may reject rows
+
+	tracer *Tracer
+	rows   rowCounts //rows moved by the current instruction
+	stats  stats
+
+	dry     bool            //only check instructions, see NewCheck
+	unknown map[string]bool //tables whose columns are unknown when dry
+}
+
+//rowCounts records the rows moved by an instruction.
+type rowCounts struct {
+	read, imported, exported, rejected int
+}
+
+//files is the state of a FILES input device.
+type files struct {
+	names  []string //files yet to be read, after the current input
+	comp   file.Compression
+	source bool //add SourceColumn to imports
 }
 
 //New creates and prepares an execution context.
@@ -94,6 +124,7 @@ func (m *Machine) Close() (errs []error) {
 			errs = append(errs, err)
 		}
 	}
+	err(m.closeRejects())
 	err(m.encoder.Close())
 	err(m.decoder.Close())
 	o := m.output
@@ -112,6 +143,38 @@ func (m *Machine) Name() string {
 	return m.name
 }
 
+//Devices reports the names of the current input and output devices.
+func (m *Machine) Devices() (input, output string) {
+	return m.input.Name(), m.output.Name()
+}
+
+//Formats reports the names of the current input and output formats.
+func (m *Machine) Formats() (decoder, encoder string) {
+	return m.decoder.Name(), m.encoder.Name()
+}
+
+//Tables reports the names of the tables and views in the main
+//and temporary databases.
+func (m *Machine) Tables() ([]string, error) {
+	s, err := m.conn.Prepare(`SELECT name FROM sqlite_master WHERE type IN ('table', 'view') AND name NOT LIKE 'sqlite_%'
+UNION SELECT name FROM sqlite_temp_master WHERE type IN ('table', 'view')
+ORDER BY name`)
+	if err != nil {
+		return nil, errint.Wrap(err)
+	}
+	defer s.Close()
+
+	it, err := s.Iter()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for it.Next() {
+		names = append(names, it.Row()[0].Text)
+	}
+	return names, it.Err()
+}
+
 func (m *Machine) setOutput(o device.Writer) error {
 	if o == nil {
 		return errint.New("no output device specified")
@@ -140,13 +203,17 @@ func (m *Machine) setOutput(o device.Writer) error {
 }
 
 func (m *Machine) drain(failed bool) (firstErr error) {
-	//TODO really need a log for tracing, especially in errors that matter
-	//to users like this
 	for i, d := range m.devs {
 		if failed {
 			d.Cancel()
 		}
-		if err := m.output.Close(); err != nil && firstErr == nil {
+		name := d.Name()
+		err := d.Close()
+		if m.tracer != nil {
+			//only the first error is returned so trace them all
+			_ = m.tracer.closed(m, name, failed, err)
+		}
+		if err != nil && firstErr == nil {
 			failed, firstErr = true, err
 		}
 		m.devs[i] = nil
@@ -181,6 +248,20 @@ func (m *Machine) setInput(in device.Reader) error {
 	return m.decoder.Init(m.input)
 }
 
+//nextFile switches the input to the next file of a FILES device.
+func (m *Machine) nextFile() error {
+	if len(m.files.names) == 0 {
+		return errint.New("no more files to read")
+	}
+	name := m.files.names[0]
+	m.files.names = m.files.names[1:]
+	f, err := file.NewReader(name, m.files.comp)
+	if err != nil {
+		return err
+	}
+	return m.setInput(f)
+}
+
 func (m *Machine) setDecoder(d format.Decoder) error {
 	if d == nil {
 		return errint.New("no decoder specified")
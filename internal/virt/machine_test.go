@@ -0,0 +1,68 @@
+package virt_test
+
+import (
+	"context"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jimmyfrasche/etlite/internal/compile"
+	"github.com/jimmyfrasche/etlite/internal/driver"
+	"github.com/jimmyfrasche/etlite/internal/lex"
+	"github.com/jimmyfrasche/etlite/internal/parse"
+	"github.com/jimmyfrasche/etlite/internal/virt"
+)
+
+func TestMain(m *testing.M) {
+	if err := driver.Init(); err != nil {
+		log.Print("could not init binding")
+		log.Fatal(err)
+	}
+	os.Exit(m.Run())
+}
+
+//run compiles the script src and runs it in a new in-memory database.
+//
+//Scripts check their results with ASSERT.
+func run(t *testing.T, src string) error {
+	return runWith(t, src, nil, nil)
+}
+
+//runWith is run with the command line arguments args
+//and the environment env.
+func runWith(t *testing.T, src string, args, env []string) error {
+	db, is, err := compile.Nodes(parse.Tokens(lex.Stream("test", strings.NewReader(src))), false)
+	if err != nil {
+		t.Fatal("could not compile script, got:", err)
+	}
+	m, err := virt.New(db, args, env)
+	if err != nil {
+		t.Fatal("could not create machine, got:", err)
+	}
+	err = m.Run(context.Background(), is)
+	for _, cerr := range m.Close() {
+		if err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+//TestArgs tests that scripts can read command line arguments
+//and environment variables.
+func TestArgs(t *testing.T) {
+	err := runWith(t, `ASSERT 'first', (SELECT @1 = 'json');
+ASSERT 'second', (SELECT @2 = 'out');
+ASSERT 'missing', (SELECT @3 IS NULL);
+ASSERT 'env', (SELECT @HOME = '/home/etlite');
+ASSERT 'env exists', @LANG;
+ASSERT 'args', (SELECT count(*) = 2 FROM sys.args);
+ASSERT 'env count', (SELECT count(*) = 2 FROM sys.env);
+SET @HOME = '/tmp';
+ASSERT 'variable before env', (SELECT @HOME = '/tmp');
+`, []string{"json", "out"}, []string{"HOME=/home/etlite", "LANG=C"})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
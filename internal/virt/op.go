@@ -0,0 +1,113 @@
+package virt
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jimmyfrasche/etlite/internal/format"
+)
+
+//go:generate stringer -type=Op -trimprefix=Op
+
+//Op is the operation of an Instruction.
+type Op int
+
+//The operations of the VM, one for each Instruction constructor.
+const (
+	OpInvalid Op = iota
+	OpErrPos
+	OpAssert
+	OpQuery
+	OpExec
+	OpImport
+	OpInsertWith
+	OpSetEncoder
+	OpSetDecoder
+	OpSetEncodingFrame
+	OpUseStdout
+	OpUseStdin
+	OpUseFileOutput
+	OpUseFileInput
+	OpUseCommandOutput
+	OpUseCommandInput
+	OpUseFilesInput
+	OpUseFileOutputFrom
+	OpUseFileInputFrom
+	OpSavepoint
+	OpRelease
+	OpDropTempTables
+	OpBeginTransaction
+	OpCommitTransaction
+	OpUserSavepoint
+	OpUserRelease
+	OpStatement
+	OpSetTimeout
+	OpSetVar
+	OpJump
+	OpJumpUnless
+	OpForEach
+	OpNext
+	OpRollback
+	OpRollbackTo
+	OpTry
+	OpEndTry
+	OpSetRejects
+)
+
+//Operand is a named operand of an Instruction.
+type Operand struct {
+	Name  string
+	Value interface{}
+}
+
+func (o Operand) String() string {
+	return o.Name + "=" + operandValue(o.Value)
+}
+
+func operandValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return strconv.Quote(v)
+	case []string:
+		qs := make([]string, len(v))
+		for i, s := range v {
+			qs[i] = strconv.Quote(s)
+		}
+		return "(" + strings.Join(qs, ", ") + ")"
+	case format.Encoder:
+		return v.Name()
+	case format.Decoder:
+		return v.Name()
+	}
+	return fmt.Sprint(v)
+}
+
+func (i Instruction) operands() string {
+	ss := make([]string, len(i.Operands))
+	for j, o := range i.Operands {
+		ss[j] = o.String()
+	}
+	return strings.Join(ss, " ")
+}
+
+func (i Instruction) String() string {
+	if len(i.Operands) == 0 {
+		return i.Op.String()
+	}
+	return i.Op.String() + " " + i.operands()
+}
+
+//Disassemble writes a listing of is to w,
+//one numbered instruction per line.
+func Disassemble(w io.Writer, is []Instruction) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 1, ' ', 0)
+	for n, i := range is {
+		if _, err := fmt.Fprintf(tw, "%d\t%s\t%s\n", n, i.Op, i.operands()); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
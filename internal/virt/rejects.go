@@ -0,0 +1,199 @@
+package virt
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/jimmyfrasche/etlite/internal/device/file"
+	"github.com/jimmyfrasche/etlite/internal/driver"
+	"github.com/jimmyfrasche/etlite/internal/format"
+	"github.com/jimmyfrasche/etlite/internal/format/csvfmt"
+	"github.com/jimmyfrasche/etlite/internal/internal/eol"
+	"github.com/jimmyfrasche/etlite/internal/internal/value"
+)
+
+//rejects is the state of an import that may reject rows.
+type rejects struct {
+	max int      //rows that may be rejected, or negative for no limit
+	n   int      //rows rejected
+	hdr []string //of the import
+
+	w      *file.Writer //if rejected rows are written
+	enc    *csvfmt.Encoder
+	header bool //written
+}
+
+//SetRejects allows the next import to reject up to max rows,
+//or any number if max is negative, that cannot be decoded
+//or that violate a constraint, rather than failing.
+//
+//If name is not empty, the rejected rows are written to the file name,
+//as CSV, with the line each began on and why it was rejected.
+func SetRejects(max int, name string) Instruction {
+	return Instruction{
+		Op:       OpSetRejects,
+		Operands: []Operand{{"max", max}, {"file", name}},
+		exec: func(ctx context.Context, m *Machine) error {
+			if m.dry {
+				return nil
+			}
+			if err := m.closeRejects(); err != nil {
+				return err
+			}
+			r := &rejects{max: max}
+			if name != "" {
+				w, err := file.NewWriter(name, file.ForName(name))
+				if err != nil {
+					return err
+				}
+				r.w = w
+				r.enc = &csvfmt.Encoder{
+					Comma:   ',',
+					Quote:   '"',
+					UseCRLF: eol.Default,
+				}
+				if err := r.enc.Init(w); err != nil {
+					w.Close()
+					return err
+				}
+			}
+			m.rejects = r
+			return nil
+		},
+	}
+}
+
+//closeRejects ends the rejects of the last import, if any.
+func (m *Machine) closeRejects() error {
+	r := m.rejects
+	if r == nil {
+		return nil
+	}
+	m.rejects = nil
+	if r.w == nil {
+		return nil
+	}
+	err := r.enc.Reset()
+	if cerr := r.enc.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := r.w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+//reject a row of the input that could not be imported because of err,
+//returning err if no more rows may be rejected.
+//The row is nil if it could not be decoded.
+func (m *Machine) reject(line int, row []value.Value, err error) error {
+	r := m.rejects
+	if r == nil {
+		return err
+	}
+	if r.max >= 0 && r.n >= r.max {
+		return fmt.Errorf("more than %d rows rejected: %s", r.max, err)
+	}
+	r.n++
+	m.rows.rejected++
+	if r.enc == nil {
+		return nil
+	}
+
+	if !r.header {
+		hdr := append([]string{"line", "error"}, r.hdr...)
+		if err := r.enc.WriteHeader("", hdr); err != nil {
+			return err
+		}
+		r.header = true
+	}
+	vs := make([]value.Value, 2+len(r.hdr))
+	if line > 0 {
+		vs[0] = value.Value{Kind: value.Integer, Text: strconv.Itoa(line)}
+	}
+	vs[1] = value.String(err.Error())
+	for i, v := range row {
+		if i < len(r.hdr) {
+			vs[2+i] = v
+		}
+	}
+	return r.enc.WriteRow(vs)
+}
+
+//inputRow is a row read from the input.
+type inputRow struct {
+	vals []*string
+	line int //the row began on, if known
+}
+
+//readRow reads the next row from the input,
+//rejecting any rows that cannot be decoded.
+func (m *Machine) readRow() (inputRow, error) {
+	for {
+		row, err := m.decoder.ReadRow()
+		if err == nil || !format.IsRowErr(err) {
+			return inputRow{row, m.line()}, err
+		}
+		m.rows.read++
+		if err := m.reject(m.line(), nil, err); err != nil {
+			return inputRow{}, err
+		}
+	}
+}
+
+//line reports the line of the input the last row read began on,
+//or 0 if the decoder cannot tell.
+func (m *Machine) line() int {
+	if d, ok := m.decoder.(format.LineDecoder); ok {
+		return d.Line()
+	}
+	return 0
+}
+
+//lineQueue is the lines of the rows queued in a bulk loader.
+type lineQueue struct {
+	first int //index of the row of lines[0] among all rows loaded
+	lines []int
+}
+
+func (q *lineQueue) push(line int) {
+	q.lines = append(q.lines, line)
+}
+
+//line of the row at index row among all rows loaded, or 0 if unknown.
+func (q *lineQueue) line(row int) int {
+	if i := row - q.first; 0 <= i && i < len(q.lines) {
+		return q.lines[i]
+	}
+	return 0
+}
+
+//trim the queue to the last pending rows.
+func (q *lineQueue) trim(pending int) {
+	if drop := len(q.lines) - pending; drop > 0 {
+		q.first += drop
+		q.lines = q.lines[:copy(q.lines, q.lines[drop:])]
+	}
+}
+
+//loaded handles the error, if any, of loading rows with bulk,
+//rejecting any row that failed because of its values.
+func (m *Machine) loaded(bulk *driver.BulkLoader, q *lineQueue, err error) error {
+	if rerr, ok := err.(*driver.RowError); ok {
+		m.rows.imported--
+		line := q.line(rerr.Row)
+		err = m.reject(line, rerr.Values, m.rowError(line, rerr))
+	}
+	q.trim(bulk.Pending())
+	return err
+}
+
+//rowError locates the failed row of rerr in the input,
+//in the same manner as the errors of a decoder.
+func (m *Machine) rowError(line int, rerr *driver.RowError) error {
+	if line > 0 {
+		return fmt.Errorf("%s:%d: %s", m.input.Name(), line, rerr.Err)
+	}
+	return fmt.Errorf("%s: row %d: %s", m.input.Name(), rerr.Row+1, rerr.Err)
+}
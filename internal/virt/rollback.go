@@ -0,0 +1,132 @@
+package virt
+
+import (
+	"context"
+
+	"github.com/jimmyfrasche/etlite/internal/device"
+	"github.com/jimmyfrasche/etlite/internal/device/std"
+	"github.com/jimmyfrasche/etlite/internal/internal/errint"
+)
+
+//mark is the state of the outputs at the start of
+//a transaction or savepoint.
+type mark struct {
+	devs   int //len(m.devs)
+	output device.Writer
+}
+
+//mark records the state of the outputs before beginning
+//a transaction or savepoint.
+func (m *Machine) mark() {
+	d := m.stack.Depth()
+	if d > len(m.marks) {
+		//should not happen but a missing mark only means nothing is cancelled
+		d = len(m.marks)
+	}
+	m.marks = append(m.marks[:d], mark{
+		devs:   len(m.devs),
+		output: m.output,
+	})
+}
+
+//Rollback rolls back the open transaction, or all savepoints,
+//cancelling any output written to devices opened since it began.
+func Rollback(q string) Instruction {
+	return Instruction{
+		Op:       OpRollback,
+		Operands: []Operand{{"sql", q}},
+		exec: func(ctx context.Context, m *Machine) error {
+			if err := m.stack.Rollback(); err != nil {
+				return errint.Wrap(err)
+			}
+			if err := m.exec(q); err != nil {
+				return err
+			}
+			err := m.cancelFrom(0)
+			m.marks = m.marks[:0]
+			//the stack is closed so the outputs opened before are done
+			if derr := m.drain(false); err == nil {
+				err = derr
+			}
+			return err
+		},
+	}
+}
+
+//RollbackTo rolls back to the savepoint name,
+//cancelling any output written to devices opened since it began.
+//The savepoint remains open.
+func RollbackTo(name, q string) Instruction {
+	return Instruction{
+		Op:       OpRollbackTo,
+		Operands: []Operand{{"name", name}, {"sql", q}},
+		exec: func(ctx context.Context, m *Machine) error {
+			d, err := m.stack.RollbackTo(name)
+			if err != nil {
+				return errint.Wrap(err)
+			}
+			if err := m.exec(q); err != nil {
+				return err
+			}
+			err = m.cancelFrom(d)
+			//the savepoint begins again
+			m.marks = m.marks[:d]
+			m.mark()
+			return err
+		},
+	}
+}
+
+//cancelFrom cancels the output devices opened since the start of
+//the transaction or savepoint at depth d of the stack.
+//If the current output is one of them, the output reverts to stdout.
+//
+//Output written to a device opened before then cannot be undone.
+func (m *Machine) cancelFrom(d int) (firstErr error) {
+	if d >= len(m.marks) {
+		return errint.Newf("no record of outputs at depth %d", d)
+	}
+	mk := m.marks[d]
+	//devices held before d may have been drained since
+	if mk.devs > len(m.devs) {
+		mk.devs = len(m.devs)
+	}
+
+	cancel := func(w device.Writer) {
+		w.Cancel()
+		name := w.Name()
+		err := w.Close()
+		if m.tracer != nil {
+			_ = m.tracer.closed(m, name, true, err)
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	kept := m.devs[:mk.devs]
+	for _, w := range m.devs[mk.devs:] {
+		if w == mk.output {
+			kept = append(kept, w)
+			continue
+		}
+		cancel(w)
+	}
+	for i := len(kept); i < len(m.devs); i++ {
+		m.devs[i] = nil
+	}
+	m.devs = kept
+
+	if m.output == mk.output {
+		return firstErr
+	}
+	if err := m.encoder.Close(); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	cancel(m.output)
+	m.output = std.Out
+	if err := m.encoder.Init(m.output); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	return firstErr
+}
@@ -0,0 +1,143 @@
+package virt
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jimmyfrasche/etlite/internal/token"
+)
+
+//Stat is the statistics of a single statement of a script,
+//summed over each time it was run.
+type Stat struct {
+	Kind    string
+	Pos     token.Position
+	Runs    int //times the statement was run
+	Read    int //rows read by the decoder
+	Loaded  int //rows loaded into the database
+	Written int //rows written by the encoder
+	Elapsed time.Duration
+
+	start time.Time
+}
+
+//stats is the state of statistics collection.
+type stats struct {
+	collect, table bool
+	done           []Stat
+	at             map[token.Position]int //index of each statement in done
+	cur            *Stat
+}
+
+//CollectStats records the statistics of each statement run by m.
+//
+//If table is set, the statistics of each statement are also
+//written to sys.stats at the end of each Run.
+//They are kept outside the database until then,
+//so that they are not undone by rolling back the statements they count.
+func (m *Machine) CollectStats(table bool) {
+	m.stats.collect, m.stats.table = true, table
+}
+
+//Stats returns the statistics of each completed statement,
+//in the order they were first run.
+func (m *Machine) Stats() []Stat {
+	return m.stats.done
+}
+
+//Statement marks the start of a statement in the script.
+//
+//It sets the position of the machine to the statement,
+//so that the instructions before its first ErrPos
+//are traced and report errors at the statement.
+func Statement(kind string, p token.Poser) Instruction {
+	pos := p.Pos()
+	return Instruction{
+		Op:       OpStatement,
+		Operands: []Operand{{"kind", kind}, {"pos", pos}},
+		exec: func(ctx context.Context, m *Machine) error {
+			m.pos = pos
+			if !m.stats.collect {
+				return nil
+			}
+			m.endStat()
+			m.stats.cur = &Stat{
+				Kind:  kind,
+				Pos:   pos,
+				start: time.Now(),
+			}
+			return nil
+		},
+	}
+}
+
+//count adds the rows moved by the last instruction to the current statement.
+func (m *Machine) count() {
+	if s := m.stats.cur; s != nil {
+		s.Read += m.rows.read
+		s.Loaded += m.rows.imported
+		s.Written += m.rows.exported
+	}
+}
+
+//endStat completes the current statement, if any,
+//and adds it to the statistics of its position.
+func (m *Machine) endStat() {
+	s := m.stats.cur
+	if s == nil {
+		return
+	}
+	m.stats.cur = nil
+	s.Elapsed = time.Since(s.start)
+
+	i, ok := m.stats.at[s.Pos]
+	if !ok {
+		if m.stats.at == nil {
+			m.stats.at = map[token.Position]int{}
+		}
+		i = len(m.stats.done)
+		m.stats.at[s.Pos] = i
+		m.stats.done = append(m.stats.done, Stat{Kind: s.Kind, Pos: s.Pos})
+	}
+	d := &m.stats.done[i]
+	d.Runs++
+	d.Read += s.Read
+	d.Loaded += s.Loaded
+	d.Written += s.Written
+	d.Elapsed += s.Elapsed
+}
+
+//flushStats replaces the contents of sys.stats with the statistics collected,
+//if requested.
+func (m *Machine) flushStats() error {
+	if !m.stats.table {
+		return nil
+	}
+	if err := m.sys.ClearStats(); err != nil {
+		return err
+	}
+	for _, s := range m.stats.done {
+		if err := m.sys.AddStat(s.Kind, s.Pos.String(), s.Runs, s.Read, s.Loaded, s.Written, s.Elapsed.Seconds()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//WriteStats writes a table of the statistics of each completed statement to w.
+func (m *Machine) WriteStats(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	if _, err := fmt.Fprintln(tw, "KIND\tPOSITION\tRUNS\tREAD\tLOADED\tWRITTEN\tTIME\t"); err != nil {
+		return err
+	}
+	for _, s := range m.stats.done {
+		_, err := fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%d\t%s\t\n", s.Kind, s.Pos, s.Runs, s.Read, s.Loaded, s.Written, s.Elapsed)
+		if err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
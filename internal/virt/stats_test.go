@@ -0,0 +1,78 @@
+package virt_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jimmyfrasche/etlite/internal/compile"
+	"github.com/jimmyfrasche/etlite/internal/lex"
+	"github.com/jimmyfrasche/etlite/internal/parse"
+	"github.com/jimmyfrasche/etlite/internal/virt"
+)
+
+//TestStats tests that the statistics of a statement are summed over
+//each time it runs and survive rolling back what it did.
+func TestStats(t *testing.T) {
+	compileScript := func(name, src string) []virt.Instruction {
+		_, is, err := compile.Nodes(parse.Tokens(lex.Stream(name, strings.NewReader(src))), false)
+		if err != nil {
+			t.Fatal("could not compile script, got:", err)
+		}
+		return is
+	}
+
+	m, err := virt.New("", nil, nil)
+	if err != nil {
+		t.Fatal("could not create machine, got:", err)
+	}
+	defer m.Close()
+	m.CollectStats(true)
+
+	err = m.Run(context.Background(), compileScript("test", `CREATE TABLE t (a);
+FOR EACH ROW IN (SELECT 1 AS x UNION ALL SELECT 2 UNION ALL SELECT 3) DO
+	INSERT INTO t (a) VALUES (@x);
+END FOR;
+SAVEPOINT s;
+INSERT INTO t (a) VALUES (4);
+ROLLBACK TO s;
+RELEASE s;
+TRY
+	INSERT INTO t (a) VALUES (5);
+	ASSERT 'boom', (SELECT 0);
+CATCH
+	SELECT 1 WHERE 0;
+END TRY;
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runs := map[string]int{}
+	for _, s := range m.Stats() {
+		if _, ok := runs[s.Pos.String()]; ok {
+			t.Errorf("%s: more than one Stat", s.Pos)
+		}
+		runs[s.Pos.String()] = s.Runs
+	}
+	for pos, exp := range map[string]int{
+		"test:1:1":  1,
+		"test:3:2":  3,
+		"test:6:1":  1,
+		"test:10:2": 1,
+		"test:11:2": 1,
+	} {
+		if got := runs[pos]; got != exp {
+			t.Errorf("%s: expected %d runs got %d", pos, exp, got)
+		}
+	}
+
+	err = m.Run(context.Background(), compileScript("check", `ASSERT 'rolled back', (SELECT group_concat(a) = '1,2,3' FROM t);
+ASSERT 'loop', (SELECT runs = 3 FROM sys.stats WHERE pos = 'test:3:2');
+ASSERT 'savepoint', (SELECT runs = 1 FROM sys.stats WHERE pos = 'test:6:1');
+ASSERT 'try', (SELECT count(*) = 2 FROM sys.stats WHERE pos IN ('test:10:2', 'test:11:2'));
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
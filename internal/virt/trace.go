@@ -0,0 +1,177 @@
+package virt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+//Tracer logs each instruction run by a Machine.
+type Tracer struct {
+	w    io.Writer
+	json bool
+	buf  *bytes.Buffer
+}
+
+//NewTracer creates a Tracer that writes to w,
+//one line per instruction, as text or, if asJSON, as JSON objects.
+func NewTracer(w io.Writer, asJSON bool) *Tracer {
+	return &Tracer{
+		w:    w,
+		json: asJSON,
+		buf:  &bytes.Buffer{},
+	}
+}
+
+//SetTracer traces each instruction run by m to t.
+//If t is nil, tracing is disabled.
+func (m *Machine) SetTracer(t *Tracer) {
+	m.tracer = t
+}
+
+//traceEntry is the record of an instruction.
+type traceEntry struct {
+	Start    time.Time              `json:"start"`
+	Pos      string                 `json:"pos"`
+	Op       string                 `json:"op"`
+	Operands map[string]interface{} `json:"operands,omitempty"`
+	Elapsed  float64                `json:"elapsed"` //seconds
+	Imported *int                   `json:"imported,omitempty"`
+	Exported *int                   `json:"exported,omitempty"`
+	Rejected *int                   `json:"rejected,omitempty"`
+	Input    string                 `json:"input,omitempty"`
+	Output   string                 `json:"output,omitempty"`
+	Decoder  string                 `json:"decoder,omitempty"`
+	Encoder  string                 `json:"encoder,omitempty"`
+	Error    string                 `json:"error,omitempty"`
+}
+
+//step runs i, tracing it if m has a Tracer.
+func (m *Machine) step(ctx context.Context, i Instruction) error {
+	m.rows = rowCounts{}
+	if m.tracer == nil || i.Op == OpErrPos || i.Op == OpStatement {
+		err := i.exec(ctx, m)
+		m.count()
+		return err
+	}
+
+	start := time.Now()
+	err := i.exec(ctx, m)
+	elapsed := time.Since(start)
+	m.count()
+
+	//errors writing the trace must not stop the script
+	_ = m.tracer.trace(m, i, start, elapsed, err)
+	return err
+}
+
+func (t *Tracer) trace(m *Machine, i Instruction, start time.Time, elapsed time.Duration, err error) error {
+	e := &traceEntry{
+		Start:   start,
+		Pos:     m.pos.String(),
+		Op:      i.Op.String(),
+		Elapsed: elapsed.Seconds(),
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+
+	switch i.Op {
+	case OpImport, OpInsertWith:
+		e.Imported = &m.rows.imported
+		if m.rows.rejected > 0 {
+			e.Rejected = &m.rows.rejected
+		}
+	case OpQuery:
+		e.Exported = &m.rows.exported
+	case OpUseStdin, OpUseFileInput, OpUseCommandInput, OpUseFilesInput, OpUseFileInputFrom:
+		e.Input = m.input.Name()
+	case OpUseStdout, OpUseFileOutput, OpUseCommandOutput, OpUseFileOutputFrom:
+		e.Output = m.output.Name()
+	case OpSetDecoder:
+		e.Decoder = m.decoder.Name()
+	case OpSetEncoder:
+		e.Encoder = m.encoder.Name()
+	}
+
+	return t.write(e, i, elapsed)
+}
+
+//closed traces closing an output device held until the end
+//of a transaction or savepoint.
+func (t *Tracer) closed(m *Machine, name string, cancelled bool, err error) error {
+	op := "Close"
+	if cancelled {
+		op = "Cancel"
+	}
+	e := &traceEntry{
+		Start:  time.Now(),
+		Pos:    m.pos.String(),
+		Op:     op,
+		Output: name,
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	return t.write(e, Instruction{}, 0)
+}
+
+func (t *Tracer) write(e *traceEntry, i Instruction, elapsed time.Duration) error {
+	t.buf.Reset()
+	if t.json {
+		if len(i.Operands) > 0 {
+			e.Operands = map[string]interface{}{}
+			for _, o := range i.Operands {
+				e.Operands[o.Name] = jsonOperand(o.Value)
+			}
+		}
+		if err := json.NewEncoder(t.buf).Encode(e); err != nil {
+			return err
+		}
+	} else {
+		t.text(e, i, elapsed)
+	}
+	_, err := t.w.Write(t.buf.Bytes())
+	return err
+}
+
+func (t *Tracer) text(e *traceEntry, i Instruction, elapsed time.Duration) {
+	fmt.Fprintf(t.buf, "%s %s %s elapsed=%s", e.Start.Format("15:04:05.000"), e.Pos, e.Op, elapsed)
+	kv := func(k, v string) {
+		if v != "" {
+			fmt.Fprintf(t.buf, " %s=%q", k, v)
+		}
+	}
+	if e.Imported != nil {
+		fmt.Fprintf(t.buf, " imported=%d", *e.Imported)
+	}
+	if e.Exported != nil {
+		fmt.Fprintf(t.buf, " exported=%d", *e.Exported)
+	}
+	if e.Rejected != nil {
+		fmt.Fprintf(t.buf, " rejected=%d", *e.Rejected)
+	}
+	kv("input", e.Input)
+	kv("output", e.Output)
+	kv("decoder", e.Decoder)
+	kv("encoder", e.Encoder)
+	kv("error", e.Error)
+	if len(i.Operands) > 0 {
+		t.buf.WriteString(" ")
+		t.buf.WriteString(i.operands())
+	}
+	t.buf.WriteString("\n")
+}
+
+//jsonOperand converts v to a value that encodes as JSON
+//the way it is displayed by Disassemble.
+func jsonOperand(v interface{}) interface{} {
+	switch v.(type) {
+	case string, []string, bool, int:
+		return v
+	}
+	return operandValue(v)
+}
@@ -0,0 +1,127 @@
+package virt
+
+import (
+	"context"
+
+	"github.com/jimmyfrasche/etlite/internal/internal/errint"
+	"github.com/jimmyfrasche/etlite/internal/internal/value"
+)
+
+//trySavepoint is the name of the savepoint of a TRY block.
+//Digital names cannot be used by scripts.
+const trySavepoint = "2"
+
+//try is the state of a TRY block.
+type try struct {
+	catch int //index of the first instruction of the CATCH block
+	depth int //of the stack before the savepoint
+	loops int //len(m.loops) before the block
+}
+
+//Try begins a TRY block in a savepoint.
+//If an instruction fails before the block is ended by EndTry,
+//the savepoint is rolled back and execution continues at instruction catch.
+//
+//When checking, it only begins the savepoint.
+func Try(catch int) Instruction {
+	return Instruction{
+		Op:       OpTry,
+		Operands: []Operand{{"catch", catch}},
+		exec: func(ctx context.Context, m *Machine) error {
+			if !m.dry {
+				m.tries = append(m.tries, try{
+					catch: catch,
+					depth: m.stack.Depth(),
+					loops: len(m.loops),
+				})
+			}
+			m.mark()
+			m.stack.Savepoint(trySavepoint)
+			return m.exec("SAVEPOINT [" + trySavepoint + "];")
+		},
+	}
+}
+
+//EndTry releases the savepoint of the innermost TRY block
+//and continues at instruction end, skipping its CATCH block.
+//
+//When checking, it only releases the savepoint,
+//so that the CATCH block is checked.
+func EndTry(end int) Instruction {
+	return Instruction{
+		Op:       OpEndTry,
+		Operands: []Operand{{"end", end}},
+		exec: func(ctx context.Context, m *Machine) error {
+			//if this fails, the block has failed
+			if err := m.exec("RELEASE [" + trySavepoint + "];"); err != nil {
+				return err
+			}
+			if err := m.stack.Release(trySavepoint); err != nil {
+				return errint.Wrap(err)
+			}
+			if m.dry {
+				return nil
+			}
+			if len(m.tries) == 0 {
+				return errint.New("EndTry outside of a TRY block")
+			}
+			m.tries = m.tries[:len(m.tries)-1]
+			m.next = end
+			return m.release()
+		},
+	}
+}
+
+//catch handles err with the innermost TRY block by rolling back
+//its savepoint, setting the script variables error and error_pos,
+//and continuing with its CATCH block.
+//
+//If the savepoint cannot be rolled back, err is returned.
+func (m *Machine) catch(err error) error {
+	t := m.tries[len(m.tries)-1]
+	m.tries = m.tries[:len(m.tries)-1]
+
+	//SQLite rolls back the entire transaction after some errors,
+	//taking the savepoint with it
+	d, rerr := m.stack.RollbackTo(trySavepoint)
+	if rerr != nil || d != t.depth {
+		return err
+	}
+	if m.exec("ROLLBACK TO ["+trySavepoint+"];") != nil {
+		return err
+	}
+	if rerr := m.exec("RELEASE [" + trySavepoint + "];"); rerr != nil {
+		return rerr
+	}
+	if rerr := m.stack.Release(trySavepoint); rerr != nil {
+		return errint.Wrap(rerr)
+	}
+
+	if cerr := m.cancelFrom(d); cerr != nil {
+		return cerr
+	}
+	m.marks = m.marks[:d]
+	if rerr := m.release(); rerr != nil {
+		return rerr
+	}
+	m.loops = m.loops[:t.loops]
+
+	vars := bindVars(
+		[]string{"error", "error_pos"},
+		[]value.Value{value.String(err.Error()), value.String(m.pos.String())},
+	)
+	if verr := m.exec(vars); verr != nil {
+		return verr
+	}
+	m.next = t.catch
+	return nil
+}
+
+//release closes the outputs held until the end of the outermost
+//transaction or savepoint, if it has ended.
+func (m *Machine) release() error {
+	if m.stack.Open() {
+		return nil
+	}
+	return m.drain(false)
+}
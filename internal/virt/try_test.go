@@ -0,0 +1,57 @@
+package virt_test
+
+import (
+	"strings"
+	"testing"
+)
+
+//TestTryCatch tests that an error in TRY rolls back its savepoint
+//and runs CATCH with the error and where it happened.
+func TestTryCatch(t *testing.T) {
+	err := run(t, `CREATE TABLE t (a);
+INSERT INTO t (a) VALUES (1);
+TRY
+	INSERT INTO t (a) VALUES (2);
+	CREATE TABLE scratch (b);
+	ASSERT 'boom', (SELECT 0);
+	INSERT INTO t (a) VALUES (3);
+CATCH
+	INSERT INTO t (a) VALUES (4);
+END TRY;
+ASSERT 'rolled back', (SELECT group_concat(a) = '1,4' FROM t);
+ASSERT 'schema rolled back', (SELECT count(*) = 0 FROM sqlite_master WHERE name = 'scratch');
+ASSERT 'error', (SELECT @error = 'assertion failure: boom');
+ASSERT 'error_pos', (SELECT @error_pos = 'test:6:2');
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+//TestTryNoError tests that CATCH is skipped when TRY succeeds.
+func TestTryNoError(t *testing.T) {
+	err := run(t, `CREATE TABLE t (a);
+TRY
+	INSERT INTO t (a) VALUES (1);
+CATCH
+	INSERT INTO t (a) VALUES (2);
+END TRY;
+ASSERT 'committed', (SELECT group_concat(a) = '1' FROM t);
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+//TestTryCatchFails tests that an error in CATCH is not caught.
+func TestTryCatchFails(t *testing.T) {
+	err := run(t, `TRY
+	ASSERT 'first', (SELECT 0);
+CATCH
+	ASSERT 'second', (SELECT 0);
+END TRY;
+`)
+	if err == nil || !strings.Contains(err.Error(), "second") {
+		t.Fatal("expected the error in CATCH, got:", err)
+	}
+}
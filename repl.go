@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jimmyfrasche/etlite/internal/ast"
+	"github.com/jimmyfrasche/etlite/internal/compile"
+	"github.com/jimmyfrasche/etlite/internal/lex"
+	"github.com/jimmyfrasche/etlite/internal/parse"
+	"github.com/jimmyfrasche/etlite/internal/token"
+	"github.com/jimmyfrasche/etlite/internal/virt"
+	"github.com/peterh/liner"
+)
+
+const (
+	prompt     = "etlite> "
+	contPrompt = "   ...> "
+	replName   = "<REPL>"
+)
+
+const replHelp = `Enter statements terminated by a semicolon.
+.device   show the current input and output devices
+.format   show the current input and output formats
+.tables   list the tables and views
+.help     show this message
+.quit     exit (as does EOF)
+`
+
+//repl is an interactive session.
+//
+//Each complete batch of statements is compiled and run
+//against the same machine, so that the database, devices,
+//formats, and any open transaction persist between batches.
+type repl struct {
+	line      *liner.State
+	comp      *compile.Compiler
+	vm        *virt.Machine
+	args, env []string
+	tracer    *virt.Tracer
+	timeout   time.Duration //of each batch, if positive
+	buf       bytes.Buffer
+}
+
+func historyFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".etlite_history")
+}
+
+//interactive runs a repl until EOF or .quit.
+func interactive(ctx context.Context, args, env []string, tracer *virt.Tracer, timeout time.Duration) (err error) {
+	r := &repl{
+		line:    liner.NewLiner(),
+		comp:    compile.New(true),
+		args:    args,
+		env:     env,
+		tracer:  tracer,
+		timeout: timeout,
+	}
+	defer r.line.Close()
+	r.line.SetCtrlCAborts(true)
+
+	hist := historyFile()
+	if hist != "" {
+		if f, err := os.Open(hist); err == nil {
+			_, _ = r.line.ReadHistory(f)
+			f.Close()
+		}
+		defer func() {
+			if f, err := os.Create(hist); err == nil {
+				_, _ = r.line.WriteHistory(f)
+				f.Close()
+			}
+		}()
+	}
+
+	defer func() {
+		if cerr := r.close(ctx); err == nil {
+			err = cerr
+		}
+	}()
+
+	for {
+		p := prompt
+		if r.buf.Len() > 0 {
+			p = contPrompt
+		}
+		ln, err := r.line.Prompt(p)
+		switch {
+		case err == liner.ErrPromptAborted:
+			//^C discards the pending input
+			r.buf.Reset()
+			continue
+		case err == io.EOF:
+			//run whatever is pending so that it is reported as incomplete
+			if r.buf.Len() > 0 {
+				fmt.Println()
+				r.exec(ctx, true)
+			}
+			fmt.Println()
+			return nil
+		case err != nil:
+			return err
+		}
+
+		if r.buf.Len() == 0 && strings.HasPrefix(strings.TrimSpace(ln), ".") {
+			r.line.AppendHistory(ln)
+			quit, err := r.meta(strings.Fields(ln))
+			if err != nil {
+				log.Println(err)
+			}
+			if quit {
+				return nil
+			}
+			continue
+		}
+
+		r.buf.WriteString(ln)
+		r.buf.WriteByte('\n')
+		r.exec(ctx, false)
+	}
+}
+
+//exec runs the pending input if it is a complete batch of statements,
+//reporting any error.
+//
+//If force, the pending input is run even if it is incomplete.
+func (r *repl) exec(ctx context.Context, force bool) {
+	nodes, complete := r.statements(force)
+	if !complete {
+		return
+	}
+	if src := strings.TrimSpace(r.buf.String()); src != "" {
+		r.line.AppendHistory(src)
+	}
+	r.buf.Reset()
+	if len(nodes) == 0 {
+		return
+	}
+	if err := r.run(ctx, nodes); err != nil {
+		log.Println(err)
+	}
+}
+
+//statements parses the pending input.
+//
+//The input is complete if it ends with a semicolon
+//and does not end in the middle of a statement,
+//such as the body of a trigger, or if force.
+func (r *repl) statements(force bool) (nodes []ast.Node, complete bool) {
+	//collect all the tokens so that a parser that stops on
+	//an error does not strand the lexer.
+	var tokens []token.Value
+	for t := range lex.Stream(replName, strings.NewReader(r.buf.String())) {
+		tokens = append(tokens, t)
+	}
+	if len(tokens) == 0 {
+		//only white space and comments
+		return nil, true
+	}
+	last := tokens[len(tokens)-1]
+	if _, ok := last.Err.(*lex.UnterminatedError); ok && !force {
+		return nil, false
+	}
+	if last.Valid() && last.Kind != token.Semicolon && !force {
+		return nil, false
+	}
+
+	ch := make(chan token.Value, len(tokens))
+	for _, t := range tokens {
+		ch <- t
+	}
+	close(ch)
+	for n := range parse.Tokens(ch) {
+		nodes = append(nodes, n)
+	}
+
+	if e, ok := nodes[len(nodes)-1].(*ast.Error); ok && e.Err == io.ErrUnexpectedEOF && !force {
+		return nil, false
+	}
+	return nodes, true
+}
+
+//run compiles and runs nodes.
+//
+//An interrupt, or running longer than the timeout, cancels the run.
+func (r *repl) run(ctx context.Context, nodes []ast.Node) error {
+	ch := make(chan ast.Node, len(nodes))
+	for _, n := range nodes {
+		ch <- n
+	}
+	close(ch)
+
+	db, is, err := r.comp.Compile(ch)
+	if err != nil {
+		return err
+	}
+	if db != "" && r.vm != nil {
+		return errors.New("USE must be first statement")
+	}
+	if err := r.machine(db); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := cancelOnSignal(cancel, os.Interrupt)
+	defer stop()
+	if r.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+
+	if err := r.vm.Run(ctx, is); err != nil {
+		r.comp.Rollback()
+		return err
+	}
+	return nil
+}
+
+//machine creates the machine on first use.
+func (r *repl) machine(db string) error {
+	if r.vm != nil {
+		return nil
+	}
+	vm, err := virt.New(db, r.args, r.env)
+	if err != nil {
+		return err
+	}
+	vm.SetTracer(r.tracer)
+	r.vm = vm
+	return nil
+}
+
+//meta handles a command starting with a dot.
+func (r *repl) meta(cmd []string) (quit bool, err error) {
+	if len(cmd) > 1 {
+		return false, fmt.Errorf("%s takes no arguments", cmd[0])
+	}
+	switch cmd[0] {
+	default:
+		return false, fmt.Errorf("unknown command %s, try .help", cmd[0])
+
+	case ".quit", ".exit":
+		return true, nil
+
+	case ".help":
+		fmt.Print(replHelp)
+
+	case ".tables":
+		if err := r.machine(""); err != nil {
+			return false, err
+		}
+		names, err := r.vm.Tables()
+		if err != nil {
+			return false, err
+		}
+		for _, name := range names {
+			fmt.Println(name)
+		}
+
+	case ".device":
+		if err := r.machine(""); err != nil {
+			return false, err
+		}
+		in, out := r.vm.Devices()
+		fmt.Printf("input:  %s\noutput: %s\n", in, out)
+
+	case ".format":
+		if err := r.machine(""); err != nil {
+			return false, err
+		}
+		dec, enc := r.vm.Formats()
+		fmt.Printf("input:  %s\noutput: %s\n", dec, enc)
+	}
+	return false, nil
+}
+
+//close ends any open transaction and shuts down the machine.
+func (r *repl) close(ctx context.Context) error {
+	if r.vm == nil {
+		return nil
+	}
+	err := r.vm.Run(ctx, r.comp.Finish())
+	for _, cerr := range r.vm.Close() {
+		if err == nil {
+			err = cerr
+		}
+	}
+	return err
+}